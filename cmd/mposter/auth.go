@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/mgurov/mposter/cmd/mposter/runparams"
+	"github.com/mgurov/mposter/internal/auth"
+)
+
+// makeAuthenticator reads the credentials of the auth method chosen, if any, failing early on the files missing or the token not obtained.
+func makeAuthenticator(params runparams.RunParams, client *http.Client) (*auth.Authenticator, error) {
+	if err := checkSingleAuthMethod(params); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case params.AuthBearerFile != "":
+		return auth.NewBearer(params.AuthBearerFile)
+	case params.AuthBasic != "":
+		return auth.NewBasic(params.AuthBasic)
+	case params.Netrc:
+		return auth.NewNetrc(netrcPath())
+	case params.OAuth2TokenUrl != "":
+		return auth.NewClientCredentials(auth.ClientCredentials{
+			TokenUrl:         params.OAuth2TokenUrl,
+			ClientId:         params.OAuth2ClientId,
+			ClientSecretFile: params.OAuth2ClientSecretFile,
+			Scope:            params.OAuth2Scope,
+			Client:           client,
+		})
+	default:
+		return nil, nil
+	}
+}
+
+// makeDryRunRedactor reads the secrets of the auth method chosen, if any, for them to be redacted from the --dry-run output,
+// making no calls, e.g. to the OAuth2 token endpoint.
+func makeDryRunRedactor(params runparams.RunParams) (*auth.Authenticator, error) {
+	if err := checkSingleAuthMethod(params); err != nil {
+		return nil, err
+	}
+	if params.OAuth2TokenUrl != "" {
+		return auth.NewClientSecretRedactor(auth.ClientCredentials{
+			TokenUrl:         params.OAuth2TokenUrl,
+			ClientId:         params.OAuth2ClientId,
+			ClientSecretFile: params.OAuth2ClientSecretFile,
+		})
+	}
+	// the rest only read the files
+	return makeAuthenticator(params, nil)
+}
+
+func checkSingleAuthMethod(params runparams.RunParams) error {
+	chosen := 0
+	for _, set := range []bool{params.AuthBearerFile != "", params.AuthBasic != "", params.Netrc, params.OAuth2TokenUrl != ""} {
+		if set {
+			chosen++
+		}
+	}
+	if chosen > 1 {
+		return fmt.Errorf("only one of --auth-bearer-file, --auth-basic, --netrc and --oauth2-token-url can be specified")
+	}
+	return nil
+}
+
+// netrcPath is $NETRC or .netrc in the home directory, as curl does.
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".netrc"
+	}
+	return filepath.Join(home, ".netrc")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mgurov/mposter/cmd/mposter/runparams"
+	"github.com/mgurov/mposter/internal/urltemplate"
+)
+
+// responseCapture adds the response body, truncated, and the json values extracted from it to the row result.
+type responseCapture struct {
+	bodyBytes int  // 0 not to capture the body
+	all       bool // capture the body of every row, not only ERR ones
+	extract   []extractRule
+}
+
+// extractRule names a json value of the response to add to the output, e.g. `jobId=$.id`.
+type extractRule struct {
+	name string
+	path urltemplate.FieldPath
+}
+
+func makeResponseCapture(params runparams.RunParams) (responseCapture, error) {
+	result := responseCapture{bodyBytes: params.CaptureBody}
+	switch params.CaptureBodyOn {
+	case "", "err":
+	case "all":
+		result.all = true
+	default:
+		return result, fmt.Errorf("capture body on '%s', expected err or all", params.CaptureBodyOn)
+	}
+
+	names := map[string]bool{}
+	for _, rule := range params.Extract {
+		equals := strings.Index(rule, "=")
+		if equals <= 0 {
+			return result, fmt.Errorf("extract '%s' should be of 'name=path' form", rule)
+		}
+		name := strings.TrimSpace(rule[:equals])
+		if names[name] {
+			return result, fmt.Errorf("extract '%s': name %s is repeated", rule, name)
+		}
+		names[name] = true
+		path, err := urltemplate.ParseFieldPath(strings.TrimSpace(rule[equals+1:]))
+		if err != nil {
+			return result, fmt.Errorf("extract '%s': %w", rule, err)
+		}
+		result.extract = append(result.extract, extractRule{name: name, path: path})
+	}
+	return result, nil
+}
+
+// needsBody tells whether the response body is to be read for the capture.
+func (c responseCapture) needsBody() bool {
+	return c.bodyBytes > 0 || len(c.extract) > 0
+}
+
+// names are the ones of the extracted values, in the order given.
+func (c responseCapture) names() []string {
+	var result []string
+	for _, rule := range c.extract {
+		result = append(result, rule.name)
+	}
+	return result
+}
+
+// apply adds the captured body and the values extracted from it to the result. The values missing aren't added.
+func (c responseCapture) apply(result LineResult, body []byte) LineResult {
+	if c.bodyBytes > 0 && (c.all || !result.Ok) {
+		result.Body = truncateBody(body, c.bodyBytes)
+	}
+	if len(c.extract) == 0 {
+		return result
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(body)))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return result
+	}
+	for _, rule := range c.extract {
+		if value, found := rule.path.Lookup(document); found {
+			if result.Extracted == nil {
+				result.Extracted = map[string]string{}
+			}
+			result.Extracted[rule.name] = value
+		}
+	}
+	return result
+}
+
+// truncateBody cuts the body to the number of bytes given, not splitting a character, "..." marking the cut.
+func truncateBody(body []byte, limit int) string {
+	if len(body) <= limit {
+		return string(body)
+	}
+	return strings.ToValidUTF8(string(body[:limit]), "") + "..."
+}
+
+// formatCaptured renders the extracted values and the body for the text output, e.g. ` jobId=42 body: "queued"`.
+func formatCaptured(result LineResult, names []string) string {
+	var formatted strings.Builder
+	for _, name := range names {
+		if value, found := result.Extracted[name]; found {
+			if value == "" || strings.ContainsAny(value, " \t\r\n\"") {
+				value = strconv.Quote(value)
+			}
+			fmt.Fprintf(&formatted, " %s=%s", name, value)
+		}
+	}
+	if result.Body != "" {
+		fmt.Fprintf(&formatted, " body: %s", strconv.Quote(result.Body))
+	}
+	return formatted.String()
+}
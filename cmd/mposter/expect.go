@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mgurov/mposter/cmd/mposter/runparams"
+	"github.com/mgurov/mposter/internal/statuscode"
+	"github.com/mgurov/mposter/internal/urltemplate"
+)
+
+// maxCheckedBody is how much of the response body is read to check the expectations against.
+const maxCheckedBody = 1 << 20
+
+// responseCheck decides the outcome of a response: SKIP for the skip codes, ERR for the codes other than the ok ones
+// or the body not meeting the expectations, OK otherwise.
+type responseCheck struct {
+	okCodes   statuscode.Matcher
+	skipCodes statuscode.Matcher
+	bodyRegex *regexp.Regexp
+	jsonRules []jsonRule
+}
+
+// jsonRule is an expectation about a response json value, e.g. `.status==done` or `$.error!=true`.
+type jsonRule struct {
+	source string
+	path   urltemplate.FieldPath
+	equal  bool
+	value  string
+}
+
+func makeResponseCheck(params runparams.RunParams) (responseCheck, error) {
+	okCodes := params.OkCodes
+	if okCodes == "" {
+		okCodes = "2xx"
+	}
+	result := responseCheck{}
+	var err error
+	if result.okCodes, err = statuscode.Parse(okCodes); err != nil {
+		return result, fmt.Errorf("ok codes: %w", err)
+	}
+	if result.skipCodes, err = statuscode.Parse(params.SkipCodes); err != nil {
+		return result, fmt.Errorf("skip codes: %w", err)
+	}
+	if params.ExpectBodyRegex != "" {
+		if result.bodyRegex, err = regexp.Compile(params.ExpectBodyRegex); err != nil {
+			return result, fmt.Errorf("expect body regex: %w", err)
+		}
+	}
+	for _, rule := range params.ExpectJson {
+		parsed, err := parseJsonRule(rule)
+		if err != nil {
+			return result, err
+		}
+		result.jsonRules = append(result.jsonRules, parsed)
+	}
+	return result, nil
+}
+
+func parseJsonRule(rule string) (jsonRule, error) {
+	result := jsonRule{source: rule, equal: true}
+	separator := strings.Index(rule, "==")
+	if notEqual := strings.Index(rule, "!="); notEqual >= 0 && (separator < 0 || notEqual < separator) {
+		separator, result.equal = notEqual, false
+	}
+	if separator <= 0 {
+		return result, fmt.Errorf("expect json '%s' should be of 'path==value' or 'path!=value' form", rule)
+	}
+
+	var err error
+	if result.path, err = urltemplate.ParseFieldPath(strings.TrimSpace(rule[:separator])); err != nil {
+		return result, fmt.Errorf("expect json '%s': %w", rule, err)
+	}
+	result.value = strings.TrimSpace(rule[separator+2:])
+	if unquoted, err := strconv.Unquote(result.value); err == nil && strings.HasPrefix(result.value, `"`) {
+		result.value = unquoted
+	}
+	return result, nil
+}
+
+// needsBody tells whether the response body is to be read for the check.
+func (c responseCheck) needsBody() bool {
+	return c.bodyRegex != nil || len(c.jsonRules) > 0
+}
+
+// check tells the result for the response status and the body, read only if needsBody.
+func (c responseCheck) check(status int, body []byte) LineResult {
+	httpStatus := fmt.Sprint("HTTP ", status)
+	if c.skipCodes.Matches(status) {
+		return LineResult{Ok: true, Skipped: true, Message: "SKIP " + httpStatus, StatusCode: status}
+	}
+	if !c.okCodes.Matches(status) {
+		return LineResult{Message: "ERR " + httpStatus, StatusCode: status}
+	}
+	if failure := c.checkBody(body); failure != "" {
+		return LineResult{Message: "ERR " + httpStatus + " " + failure, StatusCode: status}
+	}
+	return LineResult{Ok: true, Message: "OK", StatusCode: status}
+}
+
+// checkBody tells why the body doesn't meet the expectations, empty if it does.
+func (c responseCheck) checkBody(body []byte) string {
+	if c.bodyRegex != nil && !c.bodyRegex.Match(body) {
+		return fmt.Sprintf("body doesn't match '%s'", c.bodyRegex)
+	}
+	if len(c.jsonRules) == 0 {
+		return ""
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(body)))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return fmt.Sprintf("body isn't json: %v", err)
+	}
+	for _, rule := range c.jsonRules {
+		value, found := rule.path.Lookup(document)
+		if found && (value == rule.value) == rule.equal {
+			continue
+		}
+		if !found {
+			return fmt.Sprintf("expected %s, %s is missing", rule.source, rule.path)
+		}
+		return fmt.Sprintf("expected %s, got %s", rule.source, strconv.Quote(value))
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// failedRowsFile collects the raw input lines of the failed rows, so that the file can be fed back as the input.
+// A nil failedRowsFile writes nothing.
+type failedRowsFile struct {
+	file        *os.File
+	writer      *bufio.Writer
+	writeHeader bool
+	fresh       bool            // nothing has been written to the file by the previous runs
+	previous    map[string]bool // the lines written by the previous runs, not to repeat the rows re-run on resume
+}
+
+// openFailedRowsFile truncates the file unless resuming, in which case the new failures are appended
+// to the ones of the previous runs and neither the header nor the rows already there are repeated:
+// the rows after the last journal flush are re-run on resume and might fail again.
+func openFailedRowsFile(path string, header bool, resume bool) (*failedRowsFile, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	previous := map[string]bool{}
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		content, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("open failed output: %w", err)
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			previous[line] = true
+		}
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open failed output: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("open failed output: %w", err)
+	}
+
+	return &failedRowsFile{
+		file:        file,
+		writer:      bufio.NewWriter(file),
+		writeHeader: header,
+		fresh:       info.Size() == 0,
+		previous:    previous,
+	}, nil
+}
+
+// Header passes through a skipped header line if configured.
+func (f *failedRowsFile) Header(rawLine string) error {
+	if f == nil || !f.writeHeader {
+		return nil
+	}
+	return f.ColumnNames(rawLine)
+}
+
+// ColumnNames passes through the column names line, which is always needed to read the file back.
+func (f *failedRowsFile) ColumnNames(rawLine string) error {
+	if f == nil || !f.fresh {
+		return nil
+	}
+	return f.Row(rawLine)
+}
+
+func (f *failedRowsFile) Row(rawLine string) error {
+	if f == nil || f.previous[rawLine] {
+		return nil
+	}
+	if _, err := fmt.Fprintln(f.writer, rawLine); err != nil {
+		return fmt.Errorf("write failed output: %w", err)
+	}
+	return nil
+}
+
+func (f *failedRowsFile) Close() error {
+	if f == nil {
+		return nil
+	}
+	flushErr := f.writer.Flush()
+	closeErr := f.file.Close()
+	if flushErr != nil {
+		return fmt.Errorf("write failed output: %w", flushErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("write failed output: %w", closeErr)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/mgurov/mposter/cmd/mposter/runparams"
+	"github.com/mgurov/mposter/internal/urltemplate"
+)
+
+// headerTemplate is a request header with the value rendered per row.
+type headerTemplate struct {
+	name  string
+	value urltemplate.RowToString
+}
+
+// parseHeaderTemplates reads the --header-file headers followed by the -H ones, the latter replacing the former of the same name.
+// The values are templates of the same syntax as the url, not escaped.
+func parseHeaderTemplates(params runparams.RunParams, syntax urltemplate.Syntax) ([]headerTemplate, error) {
+	fileHeaders, err := readHeaderFile(params.HeaderFile)
+	if err != nil {
+		return nil, err
+	}
+
+	flagHeaders, err := parseHeaders(params.Headers, syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := map[string]bool{}
+	for _, header := range flagHeaders {
+		overridden[header.name] = true
+	}
+
+	fileHeaderTemplates, err := parseHeaders(fileHeaders, syntax)
+	if err != nil {
+		return nil, fmt.Errorf("header file %s: %w", params.HeaderFile, err)
+	}
+
+	var result []headerTemplate
+	for _, header := range fileHeaderTemplates {
+		if !overridden[header.name] {
+			result = append(result, header)
+		}
+	}
+	return append(result, flagHeaders...), nil
+}
+
+func parseHeaders(headers []string, syntax urltemplate.Syntax) ([]headerTemplate, error) {
+	var result []headerTemplate
+	for _, header := range headers {
+		colon := strings.Index(header, ":")
+		name := strings.TrimSpace(header[:colon+1])
+		if colon == -1 || name == ":" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("header '%s' should be of 'Name: value' form", header)
+		}
+		name = http.CanonicalHeaderKey(strings.TrimSuffix(name, ":"))
+
+		value, err := syntax.Parse(strings.TrimSpace(header[colon+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("parse header %s template: %w", name, err)
+		}
+		result = append(result, headerTemplate{name: name, value: value})
+	}
+	return result, nil
+}
+
+// readHeaderFile reads the headers one per line, skipping the empty ones and the # comments.
+func readHeaderFile(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open header file: %w", err)
+	}
+	defer file.Close()
+
+	var result []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			result = append(result, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read header file: %w", err)
+	}
+	return result, nil
+}
+
+// renderHeaders renders the header values for the row, refusing the line breaks.
+func renderHeaders(headers []headerTemplate, row urltemplate.Row) (http.Header, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	result := http.Header{}
+	for _, header := range headers {
+		value, err := header.value(row)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", header.name, err)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("header %s value has a line break", header.name)
+		}
+		result.Add(header.name, value)
+	}
+	return result, nil
+}
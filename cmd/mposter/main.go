@@ -2,17 +2,31 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 	"unicode"
 
 	"github.com/mgurov/mposter/cmd/mposter/runparams"
+	"github.com/mgurov/mposter/internal/auth"
+	"github.com/mgurov/mposter/internal/journal"
+	"github.com/mgurov/mposter/internal/ratelimit"
+	"github.com/mgurov/mposter/internal/retry"
+	"github.com/mgurov/mposter/internal/statuscode"
 	"github.com/mgurov/mposter/internal/tracker"
-	"github.com/mgurov/mposter/internal/urltemplate"
 )
 
 func main() {
@@ -21,120 +35,641 @@ func main() {
 		os.Exit(2)
 	}
 
-	err = run(params)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	err = run(params, signals)
+	if errors.Is(err, errInterrupted) {
+		log.Println(err)
+		os.Exit(exitCode(err))
+	}
 	if nil != err {
 		log.Fatal(err)
 	}
 }
 
-func run(params runparams.RunParams) error {
+// run processes the input until it's exhausted or a stop condition is met. The first of the signals, if any,
+// stops the run gracefully, letting the calls in flight finish within params.ShutdownGrace, the second one forces the exit.
+// The --report, if asked for, is written in any case.
+func run(params runparams.RunParams, signals <-chan os.Signal) (err error) {
+
+	// the total lines are only needed for the progress bar and the ETA of the tick lines
+	scanned, err := scanInput(params.Input, params.LogTick > 0 || wantsProgressBar(params), params.Report != "")
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	report := newRunReport(params, scanned.sha256)
+	defer func() { err = report.finish(err) }()
+
+	format, err := makeRowFormat(params)
+	if err != nil {
+		return err
+	}
+
+	totalLines := scanned.lines
+	progress := makeProgressBar(params, totalLines)
+	if progress != nil && params.Output == os.Stdout && isTerminal(os.Stdout) {
+		params.Output = progress.passThrough(os.Stdout)
+	}
+
+	capture, err := makeResponseCapture(params)
+	if err != nil {
+		return err
+	}
+	writeResult, err := makeResultWriter(params.OutputFormat, params.Output, params.HttpMethod, capture)
+	if err != nil {
+		return err
+	}
+
+	rate, err := ratelimit.ParseRate(params.Rate)
+	if err != nil {
+		return err
+	}
+	limiter := ratelimit.New(rate, params.RateBurst, params.MinimalDuration).WithLowQuota(params.RateLimitLowQuota)
+
+	retryPolicy, err := retry.NewPolicy(params.Retries, params.RetryBackoff, params.RetryOn)
+	if err != nil {
+		return err
+	}
+	retryPolicy.RateLimitRetries = params.RateLimitRetries
+
+	singleAttemptProcessor, tracker, err := makeLineUrlProcessor(params, capture)
+	if err != nil {
+		return err
+	}
+	defer func() { tracker.LogDone() }() //TODO: test this is invoked
+
+	tracker.TotalLines = totalLines
+	if progress != nil {
+		tracker.TickLog = 0
+		tracker.Logger.SetOutput(progress.passThrough(os.Stderr))
+		log.SetOutput(progress.passThrough(os.Stderr))
+		defer log.SetOutput(os.Stderr)
+	}
 
-	paramsToUrl, err := makeParamsToUrlFun(params)
+	failedRows, err := openFailedRowsFile(params.FailedOutput, params.FailedOutputHeader > 0, params.Resume)
 	if err != nil {
 		return err
 	}
+	if params.Resume {
+		if params.Journal == "" {
+			return fmt.Errorf("--resume requires --journal")
+		}
+		completed, err := journal.Read(params.Journal)
+		if err != nil {
+			return err
+		}
+		if completed > params.Skip {
+			params.Skip = completed
+		}
+	}
+
+	// without --journal, the progress is still tracked to hint on how to continue an interrupted run
+	runJournal := journal.New(params.Journal)
+
+	tracker.Start()
+	sink := newLineResultSink(writeResult, tracker, runJournal, failedRows, params.CircuitDelay)
+	report.track(sink.Stats)
+	lineUrlProcessor := retrying(retryPolicy, sink.Done(), func(call RowCall) (LineResult, error) {
+		if !limiter.Wait(sink.Done()) {
+			return LineResult{}, errStopped
+		}
+		result, err := singleAttemptProcessor(call)
+		if paused := limiter.Advise(result.RateLimit); paused > 0 {
+			log.Printf("rate limited: pausing the calls for %s", paused)
+		}
+		return result, err
+	})
+	if progress != nil {
+		defer progress.Start(sink.Stats, progressInterval)()
+	}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go watchSignals(signals, sink, params.ShutdownGrace, finished, func(reason string) {
+		report.finish(fmt.Errorf("%w, forced by %s", errInterrupted, reason))
+		sink.ForceQuit(reason, continueHint(params, runJournal.Completed()))
+	})
 
-	lineUrlProcessor, onProcessingDone := makeLineUrlProcessor(params)
-	defer onProcessingDone() //TODO: test this is invoked
+	parallel := params.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
 
-	skipLines := params.Skip
+	jobs := make(chan lineJob)
+	workers := sync.WaitGroup{}
+	for i := 0; i < parallel; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				var job lineJob
+				select {
+				case <-sink.Done():
+					return
+				case nextJob, ok := <-jobs:
+					if !ok {
+						return
+					}
+					job = nextJob
+				}
+				if sink.Stopped() {
+					continue
+				}
+				if job.err != nil {
+					sink.Record(job, LineResult{Message: fmt.Sprint("ERR ", job.err), ErrClass: rowErrClass})
+					continue
+				}
+				if !sink.AwaitClosedCircuit(job.lineNo) {
+					continue
+				}
+				result, err := lineUrlProcessor(job.call)
+				if errors.Is(err, errStopped) {
+					continue
+				}
+				if err != nil {
+					sink.Abort(err)
+					continue
+				}
+				sink.Record(job, result)
+			}
+		}()
+	}
+
+	// the reader isn't waited for once stopped: it might be blocked on reading the input
+	readErrs := make(chan error, 1)
+	go func() {
+		readErrs <- readLines(params, format, sink, runJournal, jobs)
+		close(jobs)
+	}()
+
+	var readErr error
+	select {
+	case readErr = <-readErrs:
+	case <-sink.Done():
+	}
+	workers.Wait()
+
+	journalErr := runJournal.Close()
+	failedRowsErr := failedRows.Close()
+
+	if err := sink.Err(); err != nil {
+		if errors.Is(err, errInterrupted) {
+			return fmt.Errorf("%w, %s", err, continueHint(params, runJournal.Completed()))
+		}
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if journalErr != nil {
+		return journalErr
+	}
+	return failedRowsErr
+}
+
+// readLines feeds the non-empty input lines to the jobs channel until the input is exhausted or the sink is stopped.
+// The column names line, if the format has one, is the first non-empty line whether skipped or not, so that --skip counts
+// the input lines the same way for all the formats. It's passed to the sink as the header, as well as the --failed-output-header lines of the skipped ones.
+func readLines(params runparams.RunParams, format *rowFormat, sink *lineResultSink, runJournal *journal.Journal, jobs chan<- lineJob) error {
+
+	lineNo := 0
+	columnNamesRead := false
 
 	scanner := bufio.NewScanner(params.Input)
 	for scanner.Scan() {
-		nextLine := strings.TrimSpace(scanner.Text())
+		if sink.Stopped() {
+			return nil
+		}
+		lineNo++
+
+		rawLine := scanner.Text()
+		nextLine := strings.TrimSpace(rawLine)
+		formatLine := nextLine
+		if format.untrimmed {
+			formatLine = rawLine
+		}
+
+		if format.columnNames != nil && !columnNamesRead && nextLine != "" {
+			columnNamesRead = true
+			if err := format.columnNames(formatLine); err != nil {
+				return err
+			}
+			if err := sink.RecordColumnNames(rawLine); err != nil {
+				return err
+			}
+			runJournal.Passed(lineNo)
+			continue
+		}
 
-		if skipLines > 0 {
-			skipLines--
+		if lineNo <= params.Skip {
+			if lineNo <= params.FailedOutputHeader {
+				if err := sink.RecordHeader(lineNo, rawLine); err != nil {
+					return err
+				}
+			} else {
+				sink.RecordSkipped(lineNo)
+			}
+			runJournal.Passed(lineNo)
 			continue
 		}
 
-		//TODO: this one will probably interfere with the skip lines feature.
 		if nextLine == "" {
+			runJournal.Passed(lineNo)
 			continue
 		}
-		fmt.Fprint(params.Output, nextLine, " ")
-		urlToCall, err := paramsToUrl(nextLine)
-		if err != nil {
+
+		job := lineJob{lineNo: lineNo, raw: rawLine, line: nextLine}
+		call, err := format.lineToCall(lineNo, formatLine)
+		var rowErr rowError
+		if errors.As(err, &rowErr) {
+			job.err = rowErr
+		} else if err != nil {
 			return err
 		}
+		job.call = call
+
+		runJournal.Started(lineNo)
+		sink.RecordRead(lineNo)
+		select {
+		case jobs <- job:
+		case <-sink.Done():
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// inputScan is what's learned reading the input file ahead of the run.
+type inputScan struct {
+	lines  int    // 0 if not counted or unknown
+	sha256 string // empty if not asked for or not a file
+}
 
-		err = lineUrlProcessor(urlToCall)
+// scanInput reads the input once if it's a regular file, counting the lines and computing the sha256 only as asked.
+// Failing to count the lines isn't an error, the count being unknown then.
+func scanInput(input io.Reader, countLines, checksum bool) (inputScan, error) {
+	result := inputScan{}
+	if !countLines && !checksum {
+		return result, nil
+	}
+	file, ok := input.(*os.File)
+	if !ok {
+		return result, nil
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return result, nil
+	}
 
+	// not to move the file offset the input is read from
+	content := io.NewSectionReader(file, 0, info.Size())
+	hash := sha256.New()
+	buffer := make([]byte, 64*1024)
+	var last byte = '\n'
+	for {
+		n, err := content.Read(buffer)
+		if n > 0 {
+			result.lines += bytes.Count(buffer[:n], []byte{'\n'})
+			last = buffer[n-1]
+			if checksum {
+				hash.Write(buffer[:n])
+			}
+		}
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return err
+			if checksum {
+				return inputScan{}, fmt.Errorf("input checksum: %w", err)
+			}
+			return inputScan{}, nil
 		}
 	}
+	if last != '\n' {
+		result.lines++
+	}
+	if !countLines {
+		result.lines = 0
+	}
+	if checksum {
+		result.sha256 = hex.EncodeToString(hash.Sum(nil))
+	}
+	return result, nil
+}
 
-	return nil
+type lineJob struct {
+	lineNo int // 1-based number of the line in the input, counting the skipped and empty lines as well
+	raw    string
+	line   string
+	call   RowCall
+	err    error // the row can't be called, to be reported as an error
 }
 
-type ParamsToUrlFun func(params string) (string, error)
+// rowErrClass is the error class of the rows which can't be called.
+const rowErrClass = "row"
 
-func makeParamsToUrlFun(params runparams.RunParams) (paramsToUrl ParamsToUrlFun, err error) {
-	templated := strings.Contains(params.Url, "{{")
+// rowError is a problem with a single input row, reported as an ERR line instead of stopping the run.
+type rowError struct {
+	err error
+}
 
-	if templated {
+func (e rowError) Error() string {
+	return e.err.Error()
+}
 
-		f, err := urltemplate.Parse(params.Url)
-		if nil != err {
-			return nil, fmt.Errorf("parse url template \"%s\": %w", params.Url, err)
-		}
+func (e rowError) Unwrap() error {
+	return e.err
+}
 
-		paramsToUrl = func(line string) (string, error) {
-			row := splitRows(line, params.FieldSeparator)
-			//TODO: explicit param or guessing for whether it's a path or not.
-			return f(row)
-		}
+// lineResultSink serializes the output and the tracker updates coming from the parallel workers,
+// holds the workers while the circuit is open and remembers the first reason to stop the run.
+type lineResultSink struct {
+	mu           sync.Mutex
+	stateChanged *sync.Cond
+	writeResult  resultWriter
+	tracker      *tracker.Tracker
+	journal      *journal.Journal
+	failedRows   *failedRowsFile
+	circuitDelay time.Duration
+	probing      bool
+	stopErr      error
+	stopped      chan struct{}
+}
+
+func newLineResultSink(writeResult resultWriter, tracker *tracker.Tracker, journal *journal.Journal, failedRows *failedRowsFile, circuitDelay time.Duration) *lineResultSink {
+	result := &lineResultSink{
+		writeResult:  writeResult,
+		tracker:      tracker,
+		journal:      journal,
+		failedRows:   failedRows,
+		circuitDelay: circuitDelay,
+		stopped:      make(chan struct{}),
+	}
+	result.stateChanged = sync.NewCond(&result.mu)
+	return result
+}
+
+func (s *lineResultSink) Record(job lineJob, result LineResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopOnErr(s.writeResult(job, result))
+
+	s.tracker.Observe(tracker.Sample{
+		Called:     job.err == nil,
+		StatusCode: result.StatusCode,
+		ErrClass:   result.ErrClass,
+		Latency:    result.Latency,
+	})
+	if result.Skipped {
+		s.tracker.SkipOutcome()
+	} else if result.Ok {
+		s.tracker.Ok()
 	} else {
-		//TODO: no-escape
-		appendAsQueryParameter := strings.Contains(params.Url, "?")
-		if appendAsQueryParameter {
-			paramsToUrl = func(line string) (string, error) {
-				return params.Url + url.QueryEscape(line), nil
-			}
+		if result.StatusCode != 0 {
+			s.stopOnErr(s.tracker.HttpErr(result.StatusCode, fmt.Sprintf("line %d: %s", job.lineNo, job.line)))
+		} else if job.err != nil {
+			s.stopOnErr(s.tracker.RowErr(fmt.Sprintf("line %d: %s: %v", job.lineNo, job.line, job.err)))
 		} else {
-			paramsToUrl = func(line string) (string, error) {
-				return params.Url + url.PathEscape(line), nil
+			s.stopOnErr(s.tracker.Err())
+		}
+		s.stopOnErr(s.failedRows.Row(job.raw))
+	}
+	s.stopOnErr(s.tracker.CircuitProbed(job.lineNo, result.Ok))
+	s.stopOnErr(s.journal.Done(job.lineNo))
+	s.stateChanged.Broadcast()
+}
+
+// RecordHeader passes a skipped header line through to the failed rows file.
+func (s *lineResultSink) RecordHeader(lineNo int, rawLine string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracker.Skipped(lineNo)
+	return s.failedRows.Header(rawLine)
+}
+
+// RecordSkipped counts a line skipped as already processed.
+func (s *lineResultSink) RecordSkipped(lineNo int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracker.Skipped(lineNo)
+}
+
+// RecordRead counts a row read to be processed.
+func (s *lineResultSink) RecordRead(lineNo int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracker.Read(lineNo)
+}
+
+// RecordColumnNames passes the column names line through to the failed rows file.
+func (s *lineResultSink) RecordColumnNames(rawLine string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failedRows.ColumnNames(rawLine)
+}
+
+// Abort stops the run upon an unexpected failure of a call.
+func (s *lineResultSink) Abort(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopOnErr(err)
+	s.stateChanged.Broadcast()
+}
+
+// Interrupt stops the run gracefully: no new calls are made while the ones in flight are recorded.
+func (s *lineResultSink) Interrupt(signal os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopOnErr(fmt.Errorf("%w (%s)", errInterrupted, signal))
+	s.stateChanged.Broadcast()
+}
+
+// ForceQuit exits the process at once, reporting the statistics collected so far.
+func (s *lineResultSink) ForceQuit(reason string, hint string) {
+	s.mu.Lock()
+	s.stopOnErr(fmt.Errorf("%w, forced by %s", errInterrupted, reason))
+	s.tracker.LogDone()
+	s.journal.Close()
+	s.failedRows.Close()
+	log.Printf("%s, %s", s.stopErr, hint)
+	os.Exit(130)
+}
+
+// stopOnErr remembers the first non-nil error as the reason to stop. Expects the lock to be held.
+func (s *lineResultSink) stopOnErr(err error) {
+	if err != nil && s.stopErr == nil {
+		s.stopErr = err
+		s.tracker.Stop(err)
+		close(s.stopped)
+	}
+}
+
+// Done is closed once the run is stopped.
+func (s *lineResultSink) Done() <-chan struct{} {
+	return s.stopped
+}
+
+// AwaitClosedCircuit blocks while the circuit is open. After the circuit delay, the first worker to come
+// half-opens the circuit and proceeds with the probe call for the line given while the rest keep waiting for its result.
+// Returns false if the run has been stopped meanwhile.
+func (s *lineResultSink) AwaitClosedCircuit(lineNo int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.stopErr == nil {
+		switch s.tracker.CircuitState() {
+		case tracker.CircuitClosed:
+			return true
+		case tracker.CircuitOpen:
+			if !s.probing {
+				s.probing = true
+				s.mu.Unlock()
+				select {
+				case <-time.After(s.circuitDelay):
+				case <-s.stopped:
+				}
+				s.mu.Lock()
+				s.probing = false
+				if s.stopErr != nil {
+					return false
+				}
+				s.tracker.HalfOpenCircuit(lineNo)
+				return true
 			}
+			s.stateChanged.Wait()
+		default:
+			s.stateChanged.Wait()
 		}
 	}
+	return false
+}
+
+func (s *lineResultSink) Stopped() bool {
+	return s.Err() != nil
+}
+
+// Stats returns the run statistics collected so far.
+func (s *lineResultSink) Stats() tracker.Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tracker.Stats()
+}
+
+func (s *lineResultSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopErr
+}
+
+// makeAppendToUrlFun escapes the value appended as a query parameter if the url has the query part started already, as a path otherwise.
+func makeAppendToUrlFun(baseUrl string) func(value string) string {
+	//TODO: no-escape
+	appendAsQueryParameter := strings.Contains(baseUrl, "?")
+	if appendAsQueryParameter {
+		return func(value string) string {
+			return baseUrl + url.QueryEscape(value)
+		}
+	}
+	return func(value string) string {
+		return baseUrl + url.PathEscape(value)
+	}
+}
 
-	return
+// LineResult is the outcome of processing a single line, Message being printed next to the line.
+type LineResult struct {
+	Ok         bool
+	Skipped    bool // Ok, yet nothing done, e.g. upon --skip-codes
+	Message    string
+	StatusCode int    // 0 if no response received
+	ErrClass   string // transport error class, see classifyErr, or rowErrClass
+	Attempts   int
+	Url        string        // called, with the secrets redacted
+	Latency    time.Duration // of the final attempt
+	RateLimit  ratelimit.Advice
+	Body       string            // captured with --capture-body, empty otherwise
+	Extracted  map[string]string // by --extract, the values missing in the response left out
+}
 
+// RowCall is the http call to be made for an input row.
+type RowCall struct {
+	Url    string
+	Header http.Header // on top of the Accept and Content-Type ones, replacing them if given
+	Body   []byte      // nil for no body
 }
 
-type LineUrlProcessor func(urlToCall string) error
-type LineUrlProcessingDone func()
+// LineUrlProcessor must be safe to call concurrently. Non-nil error means an unexpected failure which aborts the run.
+type LineUrlProcessor func(call RowCall) (LineResult, error)
+
+func makeLineUrlProcessor(params runparams.RunParams, capture responseCapture) (LineUrlProcessor, *tracker.Tracker, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if params.Parallel > transport.MaxIdleConnsPerHost {
+		transport.MaxIdleConnsPerHost = params.Parallel
+	}
+	tlsConfig, err := makeTlsConfig(params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tls: %w", err)
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	httpClient := http.Client{
+		Timeout:   params.Timeout,
+		Transport: transport,
+	}
+
+	check, err := makeResponseCheck(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stopOnHttpCode, err := statuscode.Parse(params.StopOnHttpCode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("stop on http code: %w", err)
+	}
 
-func makeLineUrlProcessor(params runparams.RunParams) (LineUrlProcessor, LineUrlProcessingDone) {
 	if params.DryRun {
-		return func(urlToCall string) error {
-			fmt.Fprintln(params.Output, params.HttpMethod, urlToCall)
-			return nil
-		}, func() {}
+		redactor, err := makeDryRunRedactor(params)
+		if err != nil {
+			return nil, nil, fmt.Errorf("auth: %w", err)
+		}
+		return func(call RowCall) (LineResult, error) {
+			message := params.HttpMethod + " " + call.Url
+			if call.Body != nil {
+				message += " " + string(call.Body)
+			}
+			return LineResult{Ok: true, Message: redactor.Redact(message), Url: redactor.Redact(call.Url)}, nil
+		}, &tracker.Tracker{}, nil
+	}
+
+	authenticator, err := makeAuthenticator(params, &httpClient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("auth: %w", err)
 	}
 
 	tracker := tracker.Tracker{
 		StopOnFirstErr:            params.StopOnFirstError,
 		StopOnConsecutiveErrCount: params.StopOnErrorCount,
 		TickLog:                   params.LogTick,
+		CircuitOpenOnCount:        params.CircuitOpenOnCount,
+		CircuitMaxOpens:           params.CircuitMaxOpens,
+		StopOnHttpCode:            stopOnHttpCode,
 	}
 
 	if params.LogTick > -1 {
 		tracker.Logger = log.New(os.Stderr, "", log.LstdFlags)
 	}
 
-	httpClient := http.Client{
-		Timeout: params.Timeout,
-	}
-
 	caller := HttpCaller{
-		Tracker:    &tracker,
 		HttpClient: &httpClient,
 		Params:     params,
+		Auth:       authenticator,
+		Check:      check,
+		Capture:    capture,
 	}
 
-	return caller.Call, func() { caller.Tracker.LogDone() }
+	return caller.Call, &tracker, nil
 }
 
 func splitRows(input, fieldSeparators string) []string {
@@ -149,54 +684,93 @@ func splitRows(input, fieldSeparators string) []string {
 
 type HttpCaller struct {
 	//ParamsToUrl func(string) (string, error)
-	Tracker    *tracker.Tracker
 	HttpClient *http.Client
 	Params     runparams.RunParams
+	Auth       *auth.Authenticator // nil for no authorization
+	Check      responseCheck
+	Capture    responseCapture
 }
 
-func (c HttpCaller) Call(urlToCall string) error {
-	req, err := http.NewRequest(c.Params.HttpMethod, urlToCall, nil)
+// Call makes the call once more upon HTTP 401 if the credentials re-read differ from the rejected ones.
+// The secrets are redacted from the messages and the errors.
+func (c HttpCaller) Call(call RowCall) (LineResult, error) {
+	started := time.Now()
+	result, err := c.call(call, true)
 	if err != nil {
-		return fmt.Errorf("Unexpected error creating request to %s : %w", urlToCall, err)
+		return result, errors.New(c.Auth.Redact(err.Error()))
+	}
+	result.Message = c.Auth.Redact(result.Message)
+	result.Body = c.Auth.Redact(result.Body)
+	result.Url = c.Auth.Redact(call.Url)
+	result.Latency = time.Since(started)
+	return result, nil
+}
+
+func (c HttpCaller) call(call RowCall, refreshAuth bool) (LineResult, error) {
+	urlToCall := call.Url
+	var body io.Reader
+	if call.Body != nil {
+		body = bytes.NewReader(call.Body)
+	}
+	req, err := http.NewRequest(c.Params.HttpMethod, urlToCall, body)
+	if err != nil {
+		return LineResult{}, fmt.Errorf("Unexpected error creating request to %s : %w", urlToCall, err)
 	}
 	if c.Params.HttpAcceptType != "" {
-		req.Header.Add("Accept", c.Params.HttpAcceptType)
+		req.Header.Set("Accept", c.Params.HttpAcceptType)
 	}
 	if c.Params.HttpContentType != "" {
-		req.Header.Add("Content", c.Params.HttpContentType)
+		req.Header.Set("Content-Type", c.Params.HttpContentType)
+	}
+	for name, values := range call.Header {
+		if name == "Host" {
+			req.Host = values[0]
+			continue
+		}
+		req.Header[name] = values
+	}
+	authorization, err := c.Auth.Authorize(req)
+	if err != nil {
+		log.Printf("refresh credentials: %v", err)
 	}
 
 	resp, err := c.HttpClient.Do(req)
 
 	if err != nil {
 		if urlErr, ok := err.(*url.Error); ok {
+			errClass := classifyErr(urlErr)
 			if urlErr.Timeout() {
-				fmt.Fprintln(c.Params.Output, "ERR Timeout")
-			} else {
-				fmt.Fprintln(c.Params.Output, "ERR", urlErr)
+				return LineResult{Message: "ERR Timeout", ErrClass: errClass}, nil
 			}
-
-			if bailoutErr := c.Tracker.Err(); bailoutErr != nil {
-				return bailoutErr
-			} else {
-				return nil //swallow the error
-			}
-
-		} else {
-			return fmt.Errorf("Unexpected error posting to %s : %w", urlToCall, err)
+			return LineResult{Message: fmt.Sprint("ERR ", urlErr), ErrClass: errClass}, nil
 		}
+		return LineResult{}, fmt.Errorf("Unexpected error posting to %s : %w", urlToCall, err)
 	}
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode/100 == 2 {
-		fmt.Fprintf(c.Params.Output, "OK\n")
-		c.Tracker.Ok()
-	} else {
-		fmt.Fprintln(c.Params.Output, "ERR HTTP", resp.StatusCode)
-		if bailoutErr := c.Tracker.Err(); bailoutErr != nil {
-			return bailoutErr
+	if resp.StatusCode == http.StatusUnauthorized && refreshAuth && c.Auth != nil {
+		refreshed, err := c.Auth.Refresh(req.URL.Hostname(), authorization)
+		if err != nil {
+			log.Printf("re-read credentials: %v", err)
+		} else if refreshed {
+			io.Copy(ioutil.Discard, resp.Body)
+			return c.call(call, false)
 		}
 	}
-	return nil
+
+	var respBody []byte
+	if c.Check.needsBody() || c.Capture.needsBody() {
+		limit := int64(maxCheckedBody)
+		if c.Capture.bodyBytes >= maxCheckedBody {
+			limit = int64(c.Capture.bodyBytes) + 1
+		}
+		if respBody, err = ioutil.ReadAll(io.LimitReader(resp.Body, limit)); err != nil {
+			return LineResult{Message: fmt.Sprint("ERR HTTP ", resp.StatusCode, " reading body: ", err), StatusCode: resp.StatusCode, ErrClass: classifyErr(err)}, nil
+		}
+	}
+
+	result := c.Capture.apply(c.Check.check(resp.StatusCode, respBody), respBody)
+	result.RateLimit = ratelimit.ParseAdvice(resp.StatusCode, resp.Header, time.Now())
+	return result, nil
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgurov/mposter/cmd/mposter/runparams"
+	"github.com/mgurov/mposter/internal/tracker"
+)
+
+const (
+	progressBarWidth = 30
+	progressInterval = 200 * time.Millisecond
+)
+
+// progressBar redraws the run progress in place on the last line of the terminal.
+// The other output to the same terminal is to come through passThrough not to get mixed with the bar.
+type progressBar struct {
+	out io.Writer
+
+	mu      sync.Mutex
+	bar     string // currently on the screen, empty if none
+	stopped bool
+}
+
+// makeProgressBar returns the bar to show on stderr instead of the tick lines if wantsProgressBar
+// and the number of the input lines is known, nil otherwise.
+func makeProgressBar(params runparams.RunParams, totalLines int) *progressBar {
+	if totalLines == 0 || !wantsProgressBar(params) {
+		return nil
+	}
+	return &progressBar{out: os.Stderr}
+}
+
+// wantsProgressBar tells whether the bar is to be shown given the input lines count, i.e. it's asked for and stderr is a terminal.
+func wantsProgressBar(params runparams.RunParams) bool {
+	return params.Progress && !params.DryRun && params.LogTick >= 0 && isTerminal(os.Stderr)
+}
+
+// isTerminal tells whether the file is a character device, e.g. not redirected to a file or a pipe.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start redraws the bar with the stats every interval until the returned function is called, which removes the bar.
+func (p *progressBar) Start(stats func() tracker.Stats, interval time.Duration) func() {
+	p.Draw(stats())
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.Draw(stats())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.clear()
+		p.stopped = true
+	}
+}
+
+// Draw replaces the bar on the screen with the one for the stats.
+func (p *progressBar) Draw(stats tracker.Stats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	p.clear()
+	p.bar = formatProgress(stats)
+	io.WriteString(p.out, p.bar)
+}
+
+// clear removes the bar from the screen. Expects the lock to be held.
+func (p *progressBar) clear() {
+	if p.bar != "" {
+		io.WriteString(p.out, "\r\033[K")
+		p.bar = ""
+	}
+}
+
+// passThrough writes to the output given, the bar being removed before and redrawn after.
+func (p *progressBar) passThrough(output io.Writer) io.Writer {
+	return progressPassThrough{progress: p, output: output}
+}
+
+type progressPassThrough struct {
+	progress *progressBar
+	output   io.Writer
+}
+
+func (w progressPassThrough) Write(b []byte) (int, error) {
+	p := w.progress
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	bar := p.bar
+	p.clear()
+	n, err := w.output.Write(b)
+	if bar != "" {
+		p.bar = bar
+		io.WriteString(p.out, bar)
+	}
+	return n, err
+}
+
+// formatProgress renders e.g. `[=======>      ]  45% 1234/2730 lines, 41.7 req/s, ETA 3m12s, OK: 1230 ERR: 4`.
+func formatProgress(stats tracker.Stats) string {
+	fraction := 0.0
+	if stats.TotalLines > 0 {
+		fraction = float64(stats.LinesDone) / float64(stats.TotalLines)
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	if fraction < 0 {
+		fraction = 0
+	}
+
+	filled := int(fraction * progressBarWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+
+	result := fmt.Sprintf("[%s] %3d%% %d/%d lines", bar, int(fraction*100), stats.LinesDone, stats.TotalLines)
+	if stats.Throughput > 0 {
+		result += fmt.Sprintf(", %.1f req/s", stats.Throughput)
+	}
+	if stats.ETA > 0 {
+		result += fmt.Sprintf(", ETA %s", stats.ETA.Round(time.Second))
+	}
+	result += fmt.Sprintf(", OK: %d ERR: %d", stats.Ok, stats.Err)
+	if stats.SkipOutcome > 0 {
+		result += fmt.Sprintf(" SKIP: %d", stats.SkipOutcome)
+	}
+	return result
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgurov/mposter/cmd/mposter/runparams"
+	"github.com/mgurov/mposter/internal/tracker"
+)
+
+// runReport is the machine readable record of the run written to the --report file at the end of the run, on abort as well.
+// A nil runReport reports nothing.
+type runReport struct {
+	path    string
+	started time.Time
+	params  map[string]interface{}
+	input   reportInput
+
+	mu    sync.Mutex
+	stats func() tracker.Stats // nil until the rows are processed
+	done  bool
+}
+
+type reportInput struct {
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256,omitempty"` // of the whole file, empty if not a file
+}
+
+type reportRows struct {
+	Read        int `json:"read"`
+	Skipped     int `json:"skipped"`
+	Ok          int `json:"ok"`
+	Err         int `json:"err"`
+	SkipOutcome int `json:"skip_outcome"` // rows completed with SKIP, e.g. upon --skip-codes
+}
+
+type reportDocument struct {
+	Started      time.Time              `json:"started"`
+	Finished     time.Time              `json:"finished"`
+	Params       map[string]interface{} `json:"params"`
+	Input        reportInput            `json:"input"`
+	Rows         reportRows             `json:"rows"`
+	StatusCodes  map[string]int         `json:"status_codes"`
+	ErrorClasses map[string]int         `json:"error_classes"`
+	StopReason   string                 `json:"stop_reason,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	ExitCode     int                    `json:"exit_code"`
+}
+
+// newRunReport is given the sha256 of the input file, empty if not a file.
+func newRunReport(params runparams.RunParams, inputSha256 string) *runReport {
+	if params.Report == "" {
+		return nil
+	}
+	return &runReport{
+		path:    params.Report,
+		started: time.Now(),
+		params:  reportParams(params),
+		input:   reportInput{Path: params.InputPath, Sha256: inputSha256},
+	}
+}
+
+// track sets the source of the statistics to report.
+func (r *runReport) track(stats func() tracker.Stats) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = stats
+}
+
+// finish writes the report for the run ended with the error given, once. Returns the run error, if any, the error writing the report otherwise.
+func (r *runReport) finish(runErr error) error {
+	if r == nil {
+		return runErr
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return runErr
+	}
+	r.done = true
+
+	document := reportDocument{
+		Started:      r.started,
+		Finished:     time.Now(),
+		Params:       r.params,
+		Input:        r.input,
+		StatusCodes:  map[string]int{},
+		ErrorClasses: map[string]int{},
+		ExitCode:     exitCode(runErr),
+	}
+	if r.stats != nil {
+		stats := r.stats()
+		document.Rows = reportRows{Read: stats.Read, Skipped: stats.Skipped, Ok: stats.Ok, Err: stats.Err, SkipOutcome: stats.SkipOutcome}
+		for status, count := range stats.ByStatus {
+			document.StatusCodes[strconv.Itoa(status)] = count
+		}
+		for class, count := range stats.ByErrClass {
+			document.ErrorClasses[class] = count
+		}
+		if stats.StopReason != nil {
+			document.StopReason = stats.StopReason.Error()
+		}
+	}
+	if runErr != nil {
+		document.Error = runErr.Error()
+	}
+
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	if err := ioutil.WriteFile(r.path, append(content, '\n'), 0644); err != nil && runErr == nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	return runErr
+}
+
+// exitCode tells the code the process exits with upon the run error.
+func exitCode(runErr error) int {
+	switch {
+	case runErr == nil:
+		return 0
+	case errors.Is(runErr, errInterrupted):
+		return 130
+	default:
+		return 1
+	}
+}
+
+// sensitiveName matches the names of the headers and the query parameters whose values are redacted from the report.
+var sensitiveName = regexp.MustCompile(`(?i)auth|token|secret|passw|key|cookie|session|signature|credential`)
+
+// reportParams are the effective flag values, the secrets given literally in the url and the headers redacted.
+func reportParams(params runparams.RunParams) map[string]interface{} {
+	result := map[string]interface{}{}
+	for name, value := range params.Flags() {
+		result[name] = value
+	}
+	result["url"] = redactUrl(params.Url)
+
+	headers := []string{}
+	for _, header := range params.Headers {
+		colon := strings.Index(header, ":")
+		if colon > 0 && sensitiveName.MatchString(header[:colon]) {
+			header = header[:colon] + ": ***"
+		}
+		headers = append(headers, header)
+	}
+	result["H"] = headers
+	return result
+}
+
+// redactUrl hides the password of the user info and the values of the query parameters named like secrets.
+func redactUrl(rawUrl string) string {
+	if scheme := strings.Index(rawUrl, "://"); scheme >= 0 {
+		authorityStart := scheme + len("://")
+		authority := rawUrl[authorityStart:]
+		if end := strings.IndexAny(authority, "/?#"); end >= 0 {
+			authority = authority[:end]
+		}
+		if at := strings.LastIndex(authority, "@"); at >= 0 {
+			if colon := strings.Index(authority[:at], ":"); colon >= 0 {
+				rawUrl = rawUrl[:authorityStart+colon+1] + "***" + rawUrl[authorityStart+at:]
+			}
+		}
+	}
+
+	question := strings.Index(rawUrl, "?")
+	if question < 0 {
+		return rawUrl
+	}
+	parameters := strings.Split(rawUrl[question+1:], "&")
+	for i, parameter := range parameters {
+		equals := strings.Index(parameter, "=")
+		if equals > 0 && equals < len(parameter)-1 && sensitiveName.MatchString(parameter[:equals]) {
+			parameters[i] = parameter[:equals+1] + "***"
+		}
+	}
+	return rawUrl[:question+1] + strings.Join(parameters, "&")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resultWriter prints the outcome of a row to the output.
+type resultWriter func(job lineJob, result LineResult) error
+
+// resultRecord is a row outcome as printed by the structured output formats.
+type resultRecord struct {
+	Line       int               `json:"line"`
+	Input      string            `json:"input"`
+	Url        string            `json:"url"`
+	Method     string            `json:"method"`
+	Result     string            `json:"result"` // OK, ERR or SKIP
+	Status     int               `json:"status"` // 0 if no response received
+	ErrorClass string            `json:"error_class"`
+	Error      string            `json:"error"`
+	LatencyMs  float64           `json:"latency_ms"`
+	Attempts   int               `json:"attempts"`
+	Body       string            `json:"body,omitempty"`      // with --capture-body
+	Extracted  map[string]string `json:"extracted,omitempty"` // with --extract
+}
+
+var resultRecordColumns = []string{"line", "input", "url", "method", "result", "status", "error_class", "error", "latency_ms", "attempts"}
+
+// makeResultWriter prints the captured response body and the extracted values as well, the csv having a column for each.
+func makeResultWriter(outputFormat string, output io.Writer, method string, capture responseCapture) (resultWriter, error) {
+	extracted := capture.names()
+	switch outputFormat {
+	case "", "text":
+		return func(job lineJob, result LineResult) error {
+			message := result.Message
+			if result.Attempts > 1 {
+				message += fmt.Sprintf(" after %d attempts", result.Attempts)
+			}
+			message += formatCaptured(result, extracted)
+			_, err := fmt.Fprintln(output, job.line, message)
+			return err
+		}, nil
+	case "jsonl":
+		encoder := json.NewEncoder(output)
+		encoder.SetEscapeHTML(false)
+		return func(job lineJob, result LineResult) error {
+			return encoder.Encode(newResultRecord(job, result, method))
+		}, nil
+	case "csv":
+		writer := csv.NewWriter(output)
+		headerWritten := false
+		columns := append([]string{}, resultRecordColumns...)
+		if capture.bodyBytes > 0 {
+			columns = append(columns, "body")
+		}
+		columns = append(columns, extracted...)
+		return func(job lineJob, result LineResult) error {
+			if !headerWritten {
+				headerWritten = true
+				if err := writer.Write(columns); err != nil {
+					return err
+				}
+			}
+			record := newResultRecord(job, result, method)
+			values := []string{
+				strconv.Itoa(record.Line),
+				record.Input,
+				record.Url,
+				record.Method,
+				record.Result,
+				strconv.Itoa(record.Status),
+				record.ErrorClass,
+				record.Error,
+				strconv.FormatFloat(record.LatencyMs, 'f', -1, 64),
+				strconv.Itoa(record.Attempts),
+			}
+			if capture.bodyBytes > 0 {
+				values = append(values, record.Body)
+			}
+			for _, name := range extracted {
+				values = append(values, record.Extracted[name])
+			}
+			writer.Write(values)
+			writer.Flush()
+			return writer.Error()
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format '%s', expected text, jsonl or csv", outputFormat)
+	}
+}
+
+func newResultRecord(job lineJob, result LineResult, method string) resultRecord {
+	record := resultRecord{
+		Line:       job.lineNo,
+		Input:      job.raw,
+		Url:        result.Url,
+		Method:     method,
+		Result:     "OK",
+		Status:     result.StatusCode,
+		ErrorClass: result.ErrClass,
+		LatencyMs:  float64(result.Latency.Round(time.Microsecond)) / float64(time.Millisecond),
+		Attempts:   result.Attempts,
+		Body:       result.Body,
+		Extracted:  result.Extracted,
+	}
+	if result.Skipped {
+		record.Result = "SKIP"
+	}
+	if !result.Ok {
+		record.Result = "ERR"
+		record.Error = strings.TrimPrefix(result.Message, "ERR ")
+	}
+	return record
+}
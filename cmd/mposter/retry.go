@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/mgurov/mposter/internal/retry"
+)
+
+// retrying repeats the call according to the policy, only the final attempt is reported, along with the number of attempts made.
+// The calls rejected with Retry-After are repeated without a delay, the call being expected to hold them as asked,
+// up to policy.RateLimitRetries times on top of the policy retries.
+// Once stopped is closed, the last attempt is reported without repeating it.
+func retrying(policy retry.Policy, stopped <-chan struct{}, call LineUrlProcessor) LineUrlProcessor {
+	return func(rowCall RowCall) (LineResult, error) {
+		var last LineResult
+		rateLimited := 0
+		for attempt := 1; ; attempt++ {
+			result, err := call(rowCall)
+			if errors.Is(err, errStopped) && attempt > 1 {
+				return last, nil
+			}
+			result.Attempts = attempt
+			var delay time.Duration
+			if err == nil && !result.Ok && result.RateLimit.RetryAfter > 0 && rateLimited < policy.RateLimitRetries {
+				rateLimited++
+			} else if retried := attempt - rateLimited; err != nil || result.Ok || !policy.ShouldRetry(retried, result.StatusCode, result.ErrClass) {
+				return result, err
+			} else {
+				delay = policy.Delay(retried)
+			}
+			if !sleepUnless(stopped, delay) {
+				return result, nil
+			}
+			last = result
+		}
+	}
+}
+
+// sleepUnless tells false if stopped is closed before the duration has passed, also a zero one.
+func sleepUnless(stopped <-chan struct{}, d time.Duration) bool {
+	select {
+	case <-stopped:
+		return false
+	default:
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stopped:
+		return false
+	}
+}
+
+// classifyErr maps transport errors onto the classes understood by --retry-on.
+func classifyErr(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return "connection-refused"
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return "connection-reset"
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "dns"
+	}
+	var recordHeaderErr tls.RecordHeaderError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	if errors.As(err, &recordHeaderErr) || errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &certificateInvalidErr) {
+		return "tls"
+	}
+	return "other"
+}
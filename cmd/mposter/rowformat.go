@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mgurov/mposter/cmd/mposter/runparams"
+	"github.com/mgurov/mposter/internal/urltemplate"
+)
+
+// LineToCallFun maps an input line, numbered from 1, onto the http call to make. Errors of rowError type are reported per row, the rest abort the run.
+type LineToCallFun func(lineNo int, line string) (RowCall, error)
+
+// rowFormat maps the input lines onto the calls to make.
+type rowFormat struct {
+	// columnNames, if set, consumes the first non-empty line of the input, skipped or not, setting lineToCall up
+	columnNames func(line string) error
+	lineToCall  LineToCallFun
+	// untrimmed tells the lines are to be given as read rather than trimmed, e.g. not to lose the leading empty tab separated values
+	untrimmed bool
+}
+
+// rowSyntax is what an input format provides to build the calls from the lines.
+type rowSyntax struct {
+	parseRow  func(line string) (urltemplate.Row, error)
+	templates urltemplate.Syntax
+	// soleValue is appended to a not templated url, nil if the format requires a template
+	soleValue func(row urltemplate.Row) string
+}
+
+func makeRowFormat(params runparams.RunParams) (*rowFormat, error) {
+	switch params.InputFormat {
+	case "", "text":
+		return makeTextRowFormat(params)
+	case "csv":
+		return makeCsvRowFormat(params)
+	case "jsonl":
+		return makeJsonlRowFormat(params)
+	default:
+		return nil, fmt.Errorf("input format '%s' isn't supported, expected text, csv or jsonl", params.InputFormat)
+	}
+}
+
+func makeTextRowFormat(params runparams.RunParams) (*rowFormat, error) {
+	lineToCall, err := makeLineToCallFun(params, rowSyntax{
+		parseRow: func(line string) (urltemplate.Row, error) {
+			return urltemplate.Row{Line: line, Columns: splitRows(line, params.FieldSeparator)}, nil
+		},
+		soleValue: func(row urltemplate.Row) string { return row.Line },
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rowFormat{lineToCall: lineToCall}, nil
+}
+
+// makeCsvRowFormat reads the column names from the header line. Each line is parsed as a separate record,
+// hence quoted values spanning multiple lines aren't supported. A not templated url gets the first column value appended.
+func makeCsvRowFormat(params runparams.RunParams) (*rowFormat, error) {
+	comma := ','
+	if params.FieldSeparator != "" {
+		if utf8.RuneCountInString(params.FieldSeparator) != 1 {
+			return nil, fmt.Errorf("csv separator should be a single character, got \"%s\"", params.FieldSeparator)
+		}
+		comma, _ = utf8.DecodeRuneInString(params.FieldSeparator)
+	}
+
+	result := &rowFormat{untrimmed: true}
+	result.columnNames = func(line string) error {
+		columns, err := parseCsvLine(line, comma)
+		if err != nil {
+			return fmt.Errorf("parse csv header: %w", err)
+		}
+
+		result.lineToCall, err = makeLineToCallFun(params, rowSyntax{
+			parseRow: func(line string) (urltemplate.Row, error) {
+				row, err := parseCsvLine(line, comma)
+				if err != nil {
+					return urltemplate.Row{}, rowError{err}
+				}
+				return urltemplate.Row{Line: line, Columns: row}, nil
+			},
+			templates: urltemplate.Syntax{Columns: columns},
+			soleValue: func(row urltemplate.Row) string { return row.Columns[0] },
+		})
+		return err
+	}
+	return result, nil
+}
+
+func parseCsvLine(line string, comma rune) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	// the white space separator would be trimmed as well
+	reader.TrimLeadingSpace = !unicode.IsSpace(comma)
+	row, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("parse csv line: %w", err)
+	}
+	return row, nil
+}
+
+// makeJsonlRowFormat treats each line as a json document. The url should be a template with the field path placeholders.
+// The lines failing to parse or missing the fields referred to are reported as the row errors.
+func makeJsonlRowFormat(params runparams.RunParams) (*rowFormat, error) {
+	if !strings.Contains(params.Url, "{{") {
+		return nil, fmt.Errorf("jsonl input requires url template with field placeholders, e.g. {{.id}}")
+	}
+
+	lineToCall, err := makeLineToCallFun(params, rowSyntax{
+		parseRow: func(line string) (urltemplate.Row, error) {
+			decoder := json.NewDecoder(strings.NewReader(line))
+			decoder.UseNumber()
+			var document interface{}
+			if err := decoder.Decode(&document); err != nil {
+				return urltemplate.Row{}, rowError{fmt.Errorf("parse json line: %w", err)}
+			}
+			if decoder.More() {
+				return urltemplate.Row{}, rowError{fmt.Errorf("parse json line: unexpected data after the document")}
+			}
+			return urltemplate.Row{Line: line, Document: document}, nil
+		},
+		templates: urltemplate.Syntax{Fields: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rowFormat{lineToCall: lineToCall}, nil
+}
+
+// makeLineToCallFun renders the url, the header and the body templates, if any, with the rows parsed from the lines.
+func makeLineToCallFun(params runparams.RunParams, syntax rowSyntax) (LineToCallFun, error) {
+
+	var rowToUrl urltemplate.RowToString
+	if strings.Contains(params.Url, "{{") {
+		urlSyntax := syntax.templates
+		urlSyntax.Url = true
+		f, err := urlSyntax.Parse(params.Url)
+		if nil != err {
+			return nil, fmt.Errorf("parse url template \"%s\": %w", params.Url, err)
+		}
+		rowToUrl = f
+	} else {
+		appendToUrl := makeAppendToUrlFun(params.Url)
+		rowToUrl = func(row urltemplate.Row) (string, error) {
+			return appendToUrl(syntax.soleValue(row)), nil
+		}
+	}
+
+	var rowToBody urltemplate.RowToString
+	if params.BodyTemplate != "" {
+		if params.LineAsBody {
+			return nil, fmt.Errorf("either body template or line as body can be specified")
+		}
+		bodyTemplate, err := readBodyTemplate(params.BodyTemplate)
+		if err != nil {
+			return nil, err
+		}
+		bodySyntax := syntax.templates
+		bodySyntax.Escape = bodyEscape(params.HttpContentType)
+		if rowToBody, err = bodySyntax.Parse(bodyTemplate); err != nil {
+			return nil, fmt.Errorf("parse body template: %w", err)
+		}
+	}
+
+	headers, err := parseHeaderTemplates(params, syntax.templates)
+	if err != nil {
+		return nil, err
+	}
+
+	// the rows failing to render are reported per row
+	rendered := func(f urltemplate.RowToString, row urltemplate.Row) (string, error) {
+		result, err := f(row)
+		if err != nil {
+			return "", rowError{err}
+		}
+		return result, nil
+	}
+
+	return func(lineNo int, line string) (RowCall, error) {
+		row, err := syntax.parseRow(line)
+		if err != nil {
+			return RowCall{}, err
+		}
+		row.Number = lineNo
+		row.Uuid = urltemplate.NewUuid()
+
+		var result RowCall
+		if result.Url, err = rendered(rowToUrl, row); err != nil {
+			return RowCall{}, err
+		}
+
+		if rowToBody != nil {
+			body, err := rendered(rowToBody, row)
+			if err != nil {
+				return RowCall{}, err
+			}
+			result.Body = []byte(body)
+		} else if params.LineAsBody {
+			result.Body = []byte(line)
+		}
+
+		if result.Header, err = renderHeaders(headers, row); err != nil {
+			return RowCall{}, rowError{err}
+		}
+
+		return result, nil
+	}, nil
+}
+
+// readBodyTemplate takes the template as is or reads it from the file if prefixed with @.
+func readBodyTemplate(param string) (string, error) {
+	if !strings.HasPrefix(param, "@") {
+		return param, nil
+	}
+	content, err := ioutil.ReadFile(param[1:])
+	if err != nil {
+		return "", fmt.Errorf("read body template: %w", err)
+	}
+	return string(content), nil
+}
+
+// bodyEscape picks the escaping of the body template values fitting the content type:
+// json string escaping, so the values are to be quoted in the template, or form encoding. Nothing is escaped otherwise.
+func bodyEscape(contentType string) func(string) string {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return url.QueryEscape
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return jsonStringEscape
+	default:
+		return nil
+	}
+}
+
+func jsonStringEscape(value string) string {
+	buffer := bytes.Buffer{}
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	quoted := strings.TrimSuffix(buffer.String(), "\n")
+	return quoted[1 : len(quoted)-1]
+}
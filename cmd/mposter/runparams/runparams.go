@@ -6,19 +6,42 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type RunParams struct {
-	Input  io.Reader //TODO: test
-	Output io.Writer //TODO: test
+	Input     io.Reader //TODO: test
+	InputPath string    // "-" for stdin
+	Output    io.Writer //TODO: test
 
 	Url             string
 	HttpAcceptType  string
 	HttpContentType string
 	HttpMethod      string
+	Headers         []string // "Name: value" templates
+	HeaderFile      string
+	AuthBearerFile  string
+	AuthBasic       string // user:passwordfile
+	Netrc           bool
+	LineAsBody      bool
+	BodyTemplate    string
 	Timeout         time.Duration
 
+	OAuth2TokenUrl         string
+	OAuth2ClientId         string
+	OAuth2ClientSecretFile string
+	OAuth2Scope            string
+
+	CaCert        string
+	Cert          string
+	Key           string
+	TlsServerName string
+	Insecure      bool
+
+	InputFormat       string
+	OutputFormat      string
 	FieldSeparator    string
 	Skip              int
 	DryRun            bool
@@ -26,11 +49,52 @@ type RunParams struct {
 	LogTick           int
 	StopOnErrorCount  int
 	StopOnFirstError  bool
+	Parallel          int
+	Rate              string
+	RateBurst         int
+	MinimalDuration   time.Duration
+	Retries           int
+	RetryBackoff      string
+	RetryOn           string
+	RateLimitRetries  int
+	RateLimitLowQuota int
+
+	CircuitOpenOnCount int
+	CircuitDelay       time.Duration
+	CircuitMaxOpens    int
+
+	StopOnHttpCode string
+
+	OkCodes         string
+	SkipCodes       string
+	ExpectBodyRegex string
+	ExpectJson      []string // "path==value" or "path!=value"
+
+	CaptureBody   int      // bytes of the response body to add to the output, 0 for none
+	CaptureBodyOn string   // "err" or "all"
+	Extract       []string // "name=path"
+
+	Journal string
+	Resume  bool
+
+	FailedOutput       string
+	FailedOutputHeader int // the leading --skip lines to pass through
+
+	ShutdownGrace time.Duration
+
+	Progress bool
+	Report   string
 }
 
+// DefaultCaptureBody is the number of bytes --capture-body given without the value captures.
+const DefaultCaptureBody = 1024
+
 func NewRunParams() RunParams {
 	return RunParams{
 		Input:             os.Stdin,
+		InputPath:         "-",
+		InputFormat:       "text",
+		OutputFormat:      "text",
 		Output:            os.Stdout,
 		StopOnErrorCount:  0,
 		StopOnFirstError:  true,
@@ -38,6 +102,17 @@ func NewRunParams() RunParams {
 		LogFirstErrStatus: true,
 		HttpAcceptType:    "*/*",
 		HttpMethod:        "POST",
+		Parallel:          1,
+		RateBurst:         1,
+		RetryBackoff:      "100ms/10s",
+		RetryOn:           "5xx,429,timeout,connection-refused,connection-reset",
+		RateLimitRetries:  5,
+		RateLimitLowQuota: 10,
+		CircuitDelay:      time.Minute,
+		ShutdownGrace:     30 * time.Second,
+		OkCodes:           "2xx",
+		CaptureBodyOn:     "err",
+		Progress:          true,
 	}
 }
 
@@ -90,11 +165,33 @@ func Parse(appname string, args []string) (RunParams, error) {
 		}
 	}
 
+	if result.InputPath != "-" {
+		input, err := os.Open(result.InputPath)
+		if err != nil {
+			return result, customErrReporting(fmt.Errorf("open input: %w", err))
+		}
+		result.Input = input
+	}
+
 	return result, nil
 }
 
+// Flags tells the effective values of the command line flags by their names, along with the url.
+func (p RunParams) Flags() map[string]string {
+	flagSet := flag.NewFlagSet("", flag.ContinueOnError)
+	configureFlagSet(flagSet, &p)
+	result := map[string]string{"url": p.Url}
+	flagSet.VisitAll(func(f *flag.Flag) {
+		result[f.Name] = f.Value.String()
+	})
+	return result
+}
+
 func configureFlagSet(flagSet *flag.FlagSet, params *RunParams) {
-	flagSet.StringVar(&params.FieldSeparator, "separator", "", "row field separator. White space if not specified.")
+	flagSet.StringVar(&params.InputPath, "input", params.InputPath, "file to read the rows from, - for stdin")
+	flagSet.StringVar(&params.InputFormat, "input-format", params.InputFormat, "text for white space (and --separator) separated values, csv for comma (or --separator) separated values with the column names header or jsonl for json documents one per line")
+	flagSet.StringVar(&params.OutputFormat, "output-format", params.OutputFormat, "text for the input line followed by the result, jsonl or csv for the records with the line number, input, url, method, result, status, error class and message, latency and attempts")
+	flagSet.StringVar(&params.FieldSeparator, "separator", params.FieldSeparator, "row field separator. White space if not specified.")
 	//TODO: document
 	flagSet.BoolVar(&params.DryRun, "dry-run", params.DryRun, "prints the http calls instead of executing them if true")
 	flagSet.IntVar(&params.StopOnErrorCount, "stop-on-err-count", params.StopOnErrorCount, "Stop on consequent error results")
@@ -102,8 +199,99 @@ func configureFlagSet(flagSet *flag.FlagSet, params *RunParams) {
 	flagSet.DurationVar(&params.Timeout, "timeout", params.Timeout, "http timeout, 0 (default) meaning no timeout")
 	flagSet.IntVar(&params.LogTick, "tick", params.LogTick, "How often to log the summary status to stderr. 0 to only log the final statistics. -1 to disable the logging whatsoever.")
 	flagSet.BoolVar(&params.LogFirstErrStatus, "log-first-err-stats", params.LogFirstErrStatus, "log status to stderr upon first error encountered")
-	flagSet.StringVar(&params.HttpContentType, "http-content-type", params.HttpContentType, "specify the value for the Content http request header")
+	flagSet.StringVar(&params.HttpContentType, "http-content-type", params.HttpContentType, "specify the value for the Content-Type http request header")
 	flagSet.StringVar(&params.HttpAcceptType, "http-accept-type", params.HttpAcceptType, "specify the value for the Accept http request header")
 	flagSet.StringVar(&params.HttpMethod, "http-method", params.HttpMethod, "http method")
+	flagSet.Var((*stringList)(&params.Headers), "H", "'Name: value' http request header, the value might have the same placeholders as the url. Repeatable")
+	flagSet.StringVar(&params.AuthBearerFile, "auth-bearer-file", params.AuthBearerFile, "file with the token to send as the Authorization: Bearer header, re-read upon HTTP 401")
+	flagSet.StringVar(&params.AuthBasic, "auth-basic", params.AuthBasic, "user:passwordfile for the basic authorization, the password file re-read upon HTTP 401")
+	flagSet.BoolVar(&params.Netrc, "netrc", params.Netrc, "basic authorization with the login and password looked up by the host in $NETRC or ~/.netrc, re-read upon HTTP 401")
+	flagSet.StringVar(&params.OAuth2TokenUrl, "oauth2-token-url", params.OAuth2TokenUrl, "OAuth2 token endpoint to obtain the bearer token from by the client credentials grant, refreshed ahead of the expiry and upon HTTP 401")
+	flagSet.StringVar(&params.OAuth2ClientId, "oauth2-client-id", params.OAuth2ClientId, "OAuth2 client id")
+	flagSet.StringVar(&params.OAuth2ClientSecretFile, "oauth2-client-secret-file", params.OAuth2ClientSecretFile, "file with the OAuth2 client secret")
+	flagSet.StringVar(&params.OAuth2Scope, "oauth2-scope", params.OAuth2Scope, "space separated OAuth2 scopes to request, none if empty")
+	flagSet.StringVar(&params.CaCert, "cacert", params.CaCert, "PEM file with the CA certificates to verify the servers with instead of the system ones")
+	flagSet.StringVar(&params.Cert, "cert", params.Cert, "PEM file with the client certificate, along with --key")
+	flagSet.StringVar(&params.Key, "key", params.Key, "PEM file with the client certificate private key")
+	flagSet.StringVar(&params.TlsServerName, "tls-server-name", params.TlsServerName, "server name to verify the certificate against and to send as SNI instead of the url host")
+	flagSet.BoolVar(&params.Insecure, "insecure", params.Insecure, "don't verify the server certificates")
+	flagSet.StringVar(&params.HeaderFile, "header-file", params.HeaderFile, "file with 'Name: value' http request headers, one per line, overridden by -H ones of the same name")
+	flagSet.StringVar(&params.BodyTemplate, "body-template", params.BodyTemplate, "http request body template with the same placeholders as the url, or @file to read it from. The values are escaped according to --http-content-type: json string or form encoding")
+	flagSet.BoolVar(&params.LineAsBody, "line-as-body", params.LineAsBody, "send the input line as the http request body, e.g. with --input-format=jsonl")
 	flagSet.IntVar(&params.Skip, "skip", params.Skip, "skip first lines, e.g. header or continue")
+	flagSet.StringVar(&params.Journal, "journal", params.Journal, "file to record the number of input lines completed so far, to be picked up by --resume")
+	flagSet.BoolVar(&params.Resume, "resume", params.Resume, "continue from the line after the last one completed according to the --journal file, if present")
+	flagSet.StringVar(&params.FailedOutput, "failed-output", params.FailedOutput, "file to write the input lines of the failed rows to, as they were read, to be fed back as the input")
+	flagSet.Var(&optionalInt{value: &params.FailedOutputHeader, bare: 1}, "failed-output-header", "pass the given number of the header lines, 1 if given without the value, through to the --failed-output file, so the same --skip applies on the replay. Only the lines within --skip are passed")
+	flagSet.DurationVar(&params.ShutdownGrace, "shutdown-grace", params.ShutdownGrace, "how long to let the calls in flight finish upon SIGINT/SIGTERM before quitting, 0 for no limit. The second signal quits at once")
+	flagSet.BoolVar(&params.Progress, "progress", params.Progress, "show the progress bar on stderr instead of the --tick lines if it's a terminal and the --input is a file")
+	flagSet.StringVar(&params.Report, "report", params.Report, "file to write the json report of the run to at the end, also upon abort: the times, the effective params with the secrets redacted, the input checksum, the row counts, the stop reason and the exit code")
+	flagSet.IntVar(&params.Parallel, "parallel", params.Parallel, "number of calls to perform concurrently. The output lines come in the order of completion when greater than 1")
+	flagSet.StringVar(&params.Rate, "rate", params.Rate, "maximum calls rate, e.g. 10/s or 600/m. No limit if not specified")
+	flagSet.IntVar(&params.RateBurst, "rate-burst", params.RateBurst, "number of calls allowed to be made at once within the --rate limit")
+	flagSet.DurationVar(&params.MinimalDuration, "minimal-duration", params.MinimalDuration, "minimal duration between the starts of the consequent calls, e.g. 5s")
+	flagSet.IntVar(&params.Retries, "retries", params.Retries, "number of times to retry a failed call, 0 to disable retrying")
+	flagSet.StringVar(&params.RetryBackoff, "retry-backoff", params.RetryBackoff, "base/max delay between the retries, doubled on each attempt and randomized by half")
+	flagSet.StringVar(&params.RetryOn, "retry-on", params.RetryOn, "comma separated http codes or classes (503, 5xx) and transport errors (timeout, connection-refused, connection-reset, dns, tls) to retry on")
+	flagSet.IntVar(&params.RateLimitRetries, "rate-limit-retries", params.RateLimitRetries, "number of times to retry a row rejected with HTTP 429 or 503 and Retry-After, on top of --retries, after pausing all the calls for the time asked")
+	flagSet.IntVar(&params.RateLimitLowQuota, "rate-limit-low-quota", params.RateLimitLowQuota, "spread the calls evenly till X-RateLimit-Reset once X-RateLimit-Remaining drops to the number, the calls are paused till the reset at 0 anyway")
+	flagSet.StringVar(&params.StopOnHttpCode, "stop-on-http-code", params.StopOnHttpCode, "comma separated http codes or classes to stop the run at once upon receiving, e.g. 401,403 or 4xx")
+	flagSet.StringVar(&params.OkCodes, "ok-codes", params.OkCodes, "comma separated http codes or classes counted as OK, e.g. 2xx,404. The rest are ERR")
+	flagSet.StringVar(&params.SkipCodes, "skip-codes", params.SkipCodes, "comma separated http codes or classes counted as SKIP, e.g. 404,409 for the rows already done. Take precedence over --ok-codes and the expectations")
+	flagSet.StringVar(&params.ExpectBodyRegex, "expect-body-regex", params.ExpectBodyRegex, "regular expression the body of an --ok-codes response has to match to be OK, ERR otherwise")
+	flagSet.Var((*stringList)(&params.ExpectJson), "expect-json", "'path==value' or 'path!=value' the json body of an --ok-codes response has to meet to be OK, ERR otherwise, e.g. '.status==done'. The path is as in the url placeholders. Repeatable")
+	flagSet.Var(&optionalInt{value: &params.CaptureBody, bare: DefaultCaptureBody}, "capture-body", fmt.Sprintf("add up to the given number of bytes of the response body to the output, %d if given without the value, e.g. --capture-body=200", DefaultCaptureBody))
+	flagSet.StringVar(&params.CaptureBodyOn, "capture-body-on", params.CaptureBodyOn, "rows to --capture-body of: err or all")
+	flagSet.Var((*stringList)(&params.Extract), "extract", "'name=path' json value of the response to add to the output, e.g. 'jobId=$.id'. The path is as in the url placeholders. Repeatable")
+	flagSet.IntVar(&params.CircuitOpenOnCount, "break-circuit-open-on-count", params.CircuitOpenOnCount, "pause the run upon the given number of consequent errors, 0 to disable")
+	flagSet.DurationVar(&params.CircuitDelay, "break-circuit-delay", params.CircuitDelay, "how long to pause before probing the next row once the circuit is open")
+	flagSet.IntVar(&params.CircuitMaxOpens, "break-circuit-max-opens", params.CircuitMaxOpens, "stop the run when the circuit opens more than the given number of times, 0 for no limit")
+}
+
+// stringList is a flag to be repeated, collecting all the values given.
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// optionalInt is an int flag which can be given without the value, e.g. `--capture-body`, meaning the bare one.
+type optionalInt struct {
+	value *int
+	bare  int
+}
+
+func (f *optionalInt) IsBoolFlag() bool {
+	return true
+}
+
+func (f *optionalInt) String() string {
+	if f == nil || f.value == nil {
+		return "0"
+	}
+	return strconv.Itoa(*f.value)
+}
+
+func (f *optionalInt) Set(value string) error {
+	switch value {
+	case "true":
+		*f.value = f.bare
+	case "false":
+		*f.value = 0
+	default:
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("'%s' should be a non-negative number", value)
+		}
+		*f.value = parsed
+	}
+	return nil
 }
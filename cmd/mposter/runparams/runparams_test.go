@@ -1,6 +1,7 @@
 package runparams
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/mgurov/mposter/internal/assertions"
@@ -73,3 +74,82 @@ func TestParseUrl_ShouldSupportFurtherParsing(t *testing.T) {
 	assertions.StringEqual(t, "HttpMethod", "before url", parsed.HttpMethod)
 	assertions.StringEqual(t, "Separator", "after url", parsed.FieldSeparator)
 }
+
+func TestParseInput_ShouldFailOnMissingFile(t *testing.T) {
+	_, err := Parse("", []string{"url", "--input", "/non/existent/file"})
+
+	assertions.ErrorContains(t, "open input", err)
+}
+
+func TestParseRepeatedHeaders(t *testing.T) {
+	parsed, err := Parse("", []string{"-H", "X-A: 1", "https://host:port/path/", "-H", "X-B: {{0}}"})
+
+	assertions.NoError(t, err)
+
+	assertions.StringEqual(t, "Headers", "X-A: 1|X-B: {{0}}", strings.Join(parsed.Headers, "|"))
+}
+
+func TestParseSeparatorBeforeUrl(t *testing.T) {
+	parsed, err := Parse("", []string{"--separator=x", "url", "--dry-run"})
+
+	assertions.NoError(t, err)
+	assertions.StringEqual(t, "Separator", "x", parsed.FieldSeparator)
+}
+
+func TestParseCaptureBody(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{args: []string{"url"}, want: 0},
+		{args: []string{"--capture-body", "url"}, want: DefaultCaptureBody},
+		{args: []string{"url", "--capture-body"}, want: DefaultCaptureBody},
+		{args: []string{"--capture-body=200", "url"}, want: 200},
+		{args: []string{"url", "--capture-body=200", "--dry-run"}, want: 200},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			parsed, err := Parse("", tt.args)
+
+			assertions.NoError(t, err)
+			if parsed.CaptureBody != tt.want || parsed.Url != "url" {
+				t.Errorf("CaptureBody = %d, Url = %s, want %d, url", parsed.CaptureBody, parsed.Url, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCaptureBodyErr(t *testing.T) {
+	_, err := Parse("", []string{"--capture-body=many", "url"})
+
+	assertions.ErrorContains(t, "'many' should be a non-negative number", err)
+}
+
+func TestParseFailedOutputHeader(t *testing.T) {
+	for args, want := range map[string]int{
+		"url":                              0,
+		"--failed-output-header url":       1,
+		"--failed-output-header=2 url":     2,
+		"url --failed-output-header=false": 0,
+	} {
+		parsed, err := Parse("", strings.Fields(args))
+
+		assertions.NoError(t, err)
+		if parsed.FailedOutputHeader != want || parsed.Url != "url" {
+			t.Errorf("%s: FailedOutputHeader = %d, Url = %s, want %d, url", args, parsed.FailedOutputHeader, parsed.Url, want)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	parsed, err := Parse("", []string{"--separator=,", "-H", "X-A: 1", "https://host/path/", "--parallel=3"})
+	assertions.NoError(t, err)
+
+	flags := parsed.Flags()
+
+	assertions.StringEqual(t, "url", "https://host/path/", flags["url"])
+	assertions.StringEqual(t, "separator", ",", flags["separator"])
+	assertions.StringEqual(t, "H", "X-A: 1", flags["H"])
+	assertions.StringEqual(t, "parallel", "3", flags["parallel"])
+	assertions.StringEqual(t, "http-method", "POST", flags["http-method"])
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mgurov/mposter/cmd/mposter/runparams"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// errStopped tells the line hasn't been called as the run had been stopped meanwhile.
+var errStopped = errors.New("stopped")
+
+// watchSignals interrupts the run upon the first signal and forces the quit upon the second one
+// or if the run doesn't finish within the grace period.
+func watchSignals(signals <-chan os.Signal, sink *lineResultSink, grace time.Duration, finished <-chan struct{}, forceQuit func(reason string)) {
+	select {
+	case sig := <-signals:
+		sink.Interrupt(sig)
+	case <-finished:
+		return
+	}
+
+	var graceExpired <-chan time.Time
+	if grace > 0 {
+		graceExpired = time.After(grace)
+	}
+
+	select {
+	case sig := <-signals:
+		forceQuit(fmt.Sprint("second ", sig))
+	case <-graceExpired:
+		forceQuit(fmt.Sprint("grace period of ", grace, " expired"))
+	case <-finished:
+	}
+}
+
+// continueHint tells how to pick the run up after the lines completed.
+func continueHint(params runparams.RunParams, completed int) string {
+	if params.Journal != "" {
+		return fmt.Sprintf("to continue re-run with --journal=%s --resume (same as --skip=%d)", params.Journal, completed)
+	}
+	return fmt.Sprintf("to continue re-run with --skip=%d", completed)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/mgurov/mposter/cmd/mposter/runparams"
+)
+
+// makeTlsConfig is nil for the default TLS settings. The CA certificates given replace the system ones, as with curl.
+func makeTlsConfig(params runparams.RunParams) (*tls.Config, error) {
+	if params.CaCert == "" && params.Cert == "" && params.Key == "" && params.TlsServerName == "" && !params.Insecure {
+		return nil, nil
+	}
+
+	config := &tls.Config{ServerName: params.TlsServerName}
+
+	if params.CaCert != "" {
+		pem, err := ioutil.ReadFile(params.CaCert)
+		if err != nil {
+			return nil, fmt.Errorf("read ca certificates: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", params.CaCert)
+		}
+	}
+
+	if params.Cert != "" || params.Key != "" {
+		if params.Cert == "" || params.Key == "" {
+			return nil, fmt.Errorf("both --cert and --key are required for the client certificate")
+		}
+		certificate, err := tls.LoadX509KeyPair(params.Cert, params.Key)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	if params.Insecure {
+		log.Println("WARNING: --insecure: the server certificates aren't verified")
+		config.InsecureSkipVerify = true
+	}
+
+	return config, nil
+}
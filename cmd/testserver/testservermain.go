@@ -3,16 +3,65 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 
+	"github.com/mgurov/mposter/internal/ratelimit"
 	"github.com/mgurov/mposter/internal/testserver"
 )
 
 func main() {
 
 	portFlag := flag.String("bind", ":0", "binding to listen at. e.g. :8080 or localhost:80")
+	tlsFlag := flag.Bool("tls", false, "serve https with the certificates generated into a temporary directory")
+	requireClientCertFlag := flag.Bool("require-client-cert", false, "with -tls, only accept the clients presenting the generated client certificate")
+	rateLimitFlag := flag.String("rate-limit", "", "calls allowed per window, e.g. 10/s or 100/m, the rest rejected with HTTP 429 and Retry-After")
+	oauth2ClientFlag := flag.String("oauth2-client", "", "id:secret of the client to issue the OAuth2 tokens at /token for, the other paths requiring the latest token then")
 	flag.Parse()
 
-	server := testserver.StartNewTestServerBinding(*portFlag)
+	server := testserver.NewTestServer()
+	server.Binding = *portFlag
+
+	if *oauth2ClientFlag != "" {
+		idAndSecret := strings.SplitN(*oauth2ClientFlag, ":", 2)
+		if len(idAndSecret) != 2 {
+			fmt.Fprintln(os.Stderr, "oauth2-client should be id:secret")
+			os.Exit(2)
+		}
+		tokens := server.ServeOAuth2Tokens("/token", idAndSecret[0], idAndSecret[1])
+		server.Fallback = tokens.Protect(testserver.MakeEmptyResponseHandler(204))
+	}
+
+	if *rateLimitFlag != "" {
+		rate, err := ratelimit.ParseRate(*rateLimitFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fallback := server.Fallback
+		if fallback == nil {
+			fallback = testserver.MakeEmptyResponseHandler(204)
+		}
+		server.Fallback = testserver.NewRateLimit(rate.Count, rate.Per).Protect(fallback)
+	}
+
+	if *tlsFlag {
+		dir, err := ioutil.TempDir("", "mposter-testserver")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(dir)
+		if server.TLS, err = testserver.GenerateCertificates(dir, "localhost"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		server.RequireClientCert = *requireClientCertFlag
+		fmt.Printf("--cacert=%s --cert=%s --key=%s\n", server.TLS.CaCertFile, server.TLS.ClientCertFile, server.TLS.ClientKeyFile)
+	}
+
+	server.Start()
 
 	fmt.Printf("Listening on port %s. Press the Enter Key to terminate.\n", server.Addr())
 	fmt.Scanln() // wait for Enter Key
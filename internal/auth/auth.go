@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials tell the Authorization header value for the host, empty if none.
+type Credentials func(host string) string
+
+// loaded are the credentials read, along with the secrets to redact.
+type loaded struct {
+	credentials Credentials
+	secrets     []string
+	lifetime    time.Duration // counting from the start of loading, zero for not expiring
+}
+
+// refreshAhead is how long before the expiry the credentials are refreshed, at most a half of their lifetime.
+const refreshAhead = 30 * time.Second
+
+// Authenticator sets the Authorization header of the requests with the credentials read from the files or obtained from a token endpoint.
+// The credentials are re-read upon Refresh, e.g. on HTTP 401, to pick up the secrets rotated during the run, and ahead of the expiry, if any.
+//
+// Authenticator is safe for concurrent use. A nil Authenticator authorizes nothing.
+type Authenticator struct {
+	load func() (loaded, error)
+	now  func() time.Time
+
+	mu          sync.RWMutex
+	credentials Credentials
+	refreshAt   time.Time
+	secrets     []string // all the secrets loaded so far, to be redacted
+}
+
+func newAuthenticator(load func() (loaded, error)) (*Authenticator, error) {
+	result := &Authenticator{load: load, now: time.Now}
+	if err := result.reload(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// NewBearer sends the token read from the file as is, trimmed of the surrounding white space.
+func NewBearer(tokenFile string) (*Authenticator, error) {
+	return newAuthenticator(func() (loaded, error) {
+		token, err := readSecret(tokenFile)
+		if err != nil {
+			return loaded{}, err
+		}
+		return anyHost("Bearer "+token, token), nil
+	})
+}
+
+// NewBasic takes the user name and the path of the file with the password separated by a colon, e.g. `user:password.txt`.
+func NewBasic(userAndPasswordFile string) (*Authenticator, error) {
+	colon := strings.Index(userAndPasswordFile, ":")
+	if colon <= 0 || colon == len(userAndPasswordFile)-1 {
+		return nil, fmt.Errorf("basic auth should be user:passwordfile, got '%s'", userAndPasswordFile)
+	}
+	user, passwordFile := userAndPasswordFile[:colon], userAndPasswordFile[colon+1:]
+
+	return newAuthenticator(func() (loaded, error) {
+		password, err := readSecret(passwordFile)
+		if err != nil {
+			return loaded{}, err
+		}
+		header := basicHeader(user, password)
+		return anyHost(header, password, header[len("Basic "):]), nil
+	})
+}
+
+// NewNetrc looks the login and the password up by the request host in the netrc file, the default entry, if any, applying to the rest of the hosts.
+func NewNetrc(netrcFile string) (*Authenticator, error) {
+	return newAuthenticator(func() (loaded, error) {
+		content, err := ioutil.ReadFile(netrcFile)
+		if err != nil {
+			return loaded{}, fmt.Errorf("read netrc: %w", err)
+		}
+		machines, err := parseNetrc(string(content))
+		if err != nil {
+			return loaded{}, fmt.Errorf("parse netrc %s: %w", netrcFile, err)
+		}
+
+		headers := map[string]string{}
+		var secrets []string
+		for _, machine := range machines {
+			if _, seen := headers[machine.name]; seen || machine.password == "" {
+				continue // the first entry for the host wins
+			}
+			header := basicHeader(machine.login, machine.password)
+			headers[machine.name] = header
+			secrets = append(secrets, machine.password, header[len("Basic "):])
+		}
+		credentials := func(host string) string {
+			if header, ok := headers[host]; ok {
+				return header
+			}
+			return headers[""]
+		}
+		return loaded{credentials: credentials, secrets: secrets}, nil
+	})
+}
+
+func anyHost(header string, secrets ...string) loaded {
+	return loaded{credentials: func(string) string { return header }, secrets: secrets}
+}
+
+func readSecret(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret: %w", err)
+	}
+	secret := strings.TrimSpace(string(content))
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+func basicHeader(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// Authorize sets the Authorization header for the request host, returning the value set, empty if none.
+// The credentials due to expire are refreshed first, the error refreshing them leaving the current ones in place.
+func (a *Authenticator) Authorize(req *http.Request) (string, error) {
+	if a == nil {
+		return "", nil
+	}
+	err := a.refreshExpiring()
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	header := a.credentials(req.URL.Hostname())
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	return header, err
+}
+
+func (a *Authenticator) refreshExpiring() error {
+	a.mu.RLock()
+	expiring := !a.refreshAt.IsZero() && !a.now().Before(a.refreshAt)
+	a.mu.RUnlock()
+	if !expiring {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.refreshAt.IsZero() || a.now().Before(a.refreshAt) {
+		return nil // refreshed by another call meanwhile
+	}
+	return a.reloadLocked()
+}
+
+// Refresh re-reads the credentials unless already changed since the rejected ones were used for the host.
+// Tells whether the credentials for the host differ from the rejected ones, hence worth another try.
+func (a *Authenticator) Refresh(host, rejected string) (bool, error) {
+	if a == nil {
+		return false, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.credentials(host) != rejected {
+		return true, nil
+	}
+	if err := a.reloadLocked(); err != nil {
+		return false, err
+	}
+	return a.credentials(host) != rejected, nil
+}
+
+func (a *Authenticator) reload() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.reloadLocked()
+}
+
+func (a *Authenticator) reloadLocked() error {
+	loadedAt := a.now()
+	loaded, err := a.load()
+	if err != nil {
+		return err
+	}
+	a.credentials = loaded.credentials
+	a.refreshAt = time.Time{}
+	if loaded.lifetime > 0 {
+		ahead := refreshAhead
+		if ahead > loaded.lifetime/2 {
+			ahead = loaded.lifetime / 2
+		}
+		a.refreshAt = loadedAt.Add(loaded.lifetime - ahead)
+	}
+	for _, secret := range loaded.secrets {
+		if !containsString(a.secrets, secret) {
+			a.secrets = append(a.secrets, secret)
+		}
+	}
+	// the longer first, not to leave a part of one containing another
+	sort.Slice(a.secrets, func(i, j int) bool { return len(a.secrets[i]) > len(a.secrets[j]) })
+	return nil
+}
+
+// Redact replaces the secrets loaded so far, the current and the previous ones, with ***.
+func (a *Authenticator) Redact(message string) string {
+	if a == nil {
+		return message
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, secret := range a.secrets {
+		message = strings.Replace(message, secret, "***", -1)
+	}
+	return message
+}
+
+func containsString(values []string, value string) bool {
+	for _, it := range values {
+		if it == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mgurov/mposter/internal/assertions"
+)
+
+func TestBearer(t *testing.T) {
+	tokenFile := writeFile(t, "token", " t0k3n \n")
+
+	testee, err := NewBearer(tokenFile)
+	assertions.NoError(t, err)
+
+	req := newRequest(t, "http://host/path")
+	assertions.StringEqual(t, "applied", "Bearer t0k3n", authorized(t, testee, req))
+	assertions.StringEqual(t, "header", "Bearer t0k3n", req.Header.Get("Authorization"))
+}
+
+func TestBearerEmptyFile(t *testing.T) {
+	_, err := NewBearer(writeFile(t, "token", "\n"))
+
+	assertions.ErrorContains(t, "is empty", err)
+}
+
+func TestBasic(t *testing.T) {
+	passwordFile := writeFile(t, "password", "s3cret\n")
+
+	testee, err := NewBasic("user:" + passwordFile)
+	assertions.NoError(t, err)
+
+	req := newRequest(t, "http://host/path")
+	authorized(t, testee, req)
+	user, password, ok := req.BasicAuth()
+	if !ok || user != "user" || password != "s3cret" {
+		t.Errorf("basic auth = %v %v %v, want user s3cret", user, password, ok)
+	}
+}
+
+func TestBasicMalformed(t *testing.T) {
+	_, err := NewBasic("nopasswordfile")
+
+	assertions.ErrorContains(t, "basic auth should be user:passwordfile", err)
+}
+
+func TestNetrc(t *testing.T) {
+	netrcFile := writeFile(t, "netrc", `# comment
+machine one.example login u1 password p1
+machine two.example
+	login u2
+	password p2 # trailing comment
+
+macdef init
+machine ignored login x password y
+
+default login anyone password p0
+`)
+
+	testee, err := NewNetrc(netrcFile)
+	assertions.NoError(t, err)
+
+	for url, expected := range map[string][]string{
+		"http://one.example:8080/path": {"u1", "p1"},
+		"https://two.example/":         {"u2", "p2"},
+		"http://ignored/":              {"anyone", "p0"},
+	} {
+		req := newRequest(t, url)
+		authorized(t, testee, req)
+		user, password, _ := req.BasicAuth()
+		assertions.StringEqual(t, url+" user", expected[0], user)
+		assertions.StringEqual(t, url+" password", expected[1], password)
+	}
+}
+
+func TestNetrcWithoutDefault(t *testing.T) {
+	testee, err := NewNetrc(writeFile(t, "netrc", "machine one.example login u1 password p1\n"))
+	assertions.NoError(t, err)
+
+	req := newRequest(t, "http://other.example/")
+	assertions.StringEqual(t, "applied", "", authorized(t, testee, req))
+	assertions.StringEqual(t, "header", "", req.Header.Get("Authorization"))
+}
+
+func TestNetrcMalformed(t *testing.T) {
+	_, err := NewNetrc(writeFile(t, "netrc", "login u1 password p1\n"))
+
+	assertions.ErrorContains(t, "'login' outside of a machine entry", err)
+}
+
+func TestRefreshPicksUpRotatedSecret(t *testing.T) {
+	tokenFile := writeFile(t, "token", "old")
+
+	testee, err := NewBearer(tokenFile)
+	assertions.NoError(t, err)
+
+	changed, err := testee.Refresh("host", "Bearer old")
+	assertions.NoError(t, err)
+	if changed {
+		t.Error("expected no change without rotation")
+	}
+
+	assertions.NoError(t, ioutil.WriteFile(tokenFile, []byte("new"), 0600))
+
+	changed, err = testee.Refresh("host", "Bearer old")
+	assertions.NoError(t, err)
+	if !changed {
+		t.Error("expected the rotated token picked up")
+	}
+	assertions.StringEqual(t, "applied", "Bearer new", authorized(t, testee, newRequest(t, "http://host/")))
+
+	changed, err = testee.Refresh("host", "Bearer old")
+	assertions.NoError(t, err)
+	if !changed {
+		t.Error("expected the token refreshed already to be worth another try")
+	}
+
+	assertions.StringEqual(t, "redacted", "token *** rotated to ***", testee.Redact("token old rotated to new"))
+}
+
+func TestRefreshKeepsCredentialsOnError(t *testing.T) {
+	tokenFile := writeFile(t, "token", "t0k3n")
+
+	testee, err := NewBearer(tokenFile)
+	assertions.NoError(t, err)
+
+	assertions.NoError(t, os.Remove(tokenFile))
+
+	_, err = testee.Refresh("host", "Bearer t0k3n")
+	assertions.ErrorContains(t, "read secret", err)
+	assertions.StringEqual(t, "applied", "Bearer t0k3n", authorized(t, testee, newRequest(t, "http://host/")))
+}
+
+func TestRedactBasic(t *testing.T) {
+	testee, err := NewBasic("user:" + writeFile(t, "password", "s3cret"))
+	assertions.NoError(t, err)
+
+	assertions.StringEqual(t, "redacted", "user *** Basic ***", testee.Redact("user s3cret Basic dXNlcjpzM2NyZXQ="))
+}
+
+func TestNilAuthenticator(t *testing.T) {
+	var testee *Authenticator
+
+	assertions.StringEqual(t, "applied", "", authorized(t, testee, newRequest(t, "http://host/")))
+	assertions.StringEqual(t, "redacted", "as is", testee.Redact("as is"))
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "mposter-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	assertions.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func newRequest(t *testing.T, url string) *http.Request {
+	req, err := http.NewRequest("GET", url, nil)
+	assertions.NoError(t, err)
+	return req
+}
+
+func authorized(t *testing.T, testee *Authenticator, req *http.Request) string {
+	t.Helper()
+	applied, err := testee.Authorize(req)
+	assertions.NoError(t, err)
+	return applied
+}
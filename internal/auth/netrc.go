@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// netrcMachine is a netrc entry, the name being empty for the default one.
+type netrcMachine struct {
+	name     string
+	login    string
+	password string
+}
+
+// parseNetrc understands the machine, default, login, password and account tokens, skipping the comments and the macdef definitions.
+func parseNetrc(content string) ([]netrcMachine, error) {
+	tokens := netrcTokens(content)
+
+	var result []netrcMachine
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token == "default" {
+			result = append(result, netrcMachine{})
+			continue
+		}
+
+		if i+1 >= len(tokens) {
+			return nil, fmt.Errorf("no value for '%s'", token)
+		}
+		i++
+		value := tokens[i]
+
+		if token == "machine" {
+			result = append(result, netrcMachine{name: value})
+			continue
+		}
+		if len(result) == 0 {
+			return nil, fmt.Errorf("'%s' outside of a machine entry", token)
+		}
+		current := &result[len(result)-1]
+		switch token {
+		case "login":
+			current.login = value
+		case "password":
+			current.password = value
+		case "account":
+		default:
+			return nil, fmt.Errorf("unexpected '%s'", token)
+		}
+	}
+	return result, nil
+}
+
+func netrcTokens(content string) []string {
+	var result []string
+	lines := strings.Split(content, "\n")
+	for lineNo := 0; lineNo < len(lines); lineNo++ {
+		for _, token := range strings.Fields(lines[lineNo]) {
+			if strings.HasPrefix(token, "#") {
+				break
+			}
+			if token == "macdef" {
+				// the macro lasts till an empty line
+				for lineNo+1 < len(lines) && strings.TrimSpace(lines[lineNo+1]) != "" {
+					lineNo++
+				}
+				break
+			}
+			result = append(result, token)
+		}
+	}
+	return result
+}
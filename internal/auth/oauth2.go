@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClientCredentials configures obtaining the tokens by the OAuth2 client credentials grant.
+type ClientCredentials struct {
+	TokenUrl         string
+	ClientId         string
+	ClientSecretFile string
+	Scope            string       // space separated, none if empty
+	Client           *http.Client // to call the token endpoint with
+}
+
+// NewClientCredentials obtains the token at once, refreshing it ahead of the expiry, if told by the token endpoint, and upon Refresh.
+// The client secret file is re-read for every token.
+func NewClientCredentials(config ClientCredentials) (*Authenticator, error) {
+	if config.ClientId == "" || config.ClientSecretFile == "" {
+		return nil, fmt.Errorf("oauth2 client id and secret file are required along with the token url")
+	}
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return newAuthenticator(func() (loaded, error) {
+		return fetchToken(config, client)
+	})
+}
+
+// NewClientSecretRedactor only reads the client secret, for it to be redacted, not calling the token endpoint, e.g. for a dry run.
+// It authorizes nothing.
+func NewClientSecretRedactor(config ClientCredentials) (*Authenticator, error) {
+	if config.ClientId == "" || config.ClientSecretFile == "" {
+		return nil, fmt.Errorf("oauth2 client id and secret file are required along with the token url")
+	}
+	return newAuthenticator(func() (loaded, error) {
+		clientSecret, err := readSecret(config.ClientSecretFile)
+		if err != nil {
+			return loaded{}, err
+		}
+		return anyHost("", clientSecret), nil
+	})
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func fetchToken(config ClientCredentials, client *http.Client) (loaded, error) {
+	clientSecret, err := readSecret(config.ClientSecretFile)
+	if err != nil {
+		return loaded{}, err
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if config.Scope != "" {
+		form.Set("scope", config.Scope)
+	}
+	req, err := http.NewRequest("POST", config.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return loaded{}, fmt.Errorf("token request to %s: %w", config.TokenUrl, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// as per RFC 6749 2.3.1, the credentials are form encoded first
+	req.SetBasicAuth(url.QueryEscape(config.ClientId), url.QueryEscape(clientSecret))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return loaded{}, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return loaded{}, fmt.Errorf("read token response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return loaded{}, fmt.Errorf("token endpoint responded HTTP %d", resp.StatusCode)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return loaded{}, fmt.Errorf("parse token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return loaded{}, fmt.Errorf("token response lacks access_token")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return loaded{}, fmt.Errorf("token type '%s' isn't supported, expected bearer", token.TokenType)
+	}
+
+	result := anyHost("Bearer "+token.AccessToken, token.AccessToken, clientSecret)
+	result.lifetime = time.Duration(token.ExpiresIn) * time.Second
+	return result, nil
+}
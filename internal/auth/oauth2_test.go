@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mgurov/mposter/internal/assertions"
+	"github.com/mgurov/mposter/internal/testserver"
+)
+
+func TestClientCredentialsRefreshedAheadOfExpiry(t *testing.T) {
+	server := testserver.StartNewTestServer()
+	defer server.Shutdown()
+	tokens := server.ServeOAuth2Tokens("/token", "client id", "s3cr&t")
+	tokens.ExpiresIn = 300
+
+	testee, err := NewClientCredentials(ClientCredentials{
+		TokenUrl:         server.Addr() + "/token",
+		ClientId:         "client id",
+		ClientSecretFile: writeFile(t, "secret", "s3cr&t\n"),
+	})
+	assertions.NoError(t, err)
+
+	loadedAt := time.Now()
+	testee.now = func() time.Time { return loadedAt.Add(269 * time.Second) }
+	assertions.StringEqual(t, "before refresh", "Bearer token-1", authorized(t, testee, newRequest(t, "http://host/")))
+
+	testee.now = func() time.Time { return loadedAt.Add(271 * time.Second) }
+	assertions.StringEqual(t, "refreshed", "Bearer token-2", authorized(t, testee, newRequest(t, "http://host/")))
+	assertions.StringEqual(t, "refreshed once", "Bearer token-2", authorized(t, testee, newRequest(t, "http://host/")))
+
+	assertions.StringEqual(t, "redacted", "*** *** ***", testee.Redact("token-1 token-2 s3cr&t"))
+}
+
+func TestClientCredentialsShortLivedRefreshedAtHalfLifetime(t *testing.T) {
+	server := testserver.StartNewTestServer()
+	defer server.Shutdown()
+	tokens := server.ServeOAuth2Tokens("/token", "id", "secret")
+	tokens.ExpiresIn = 10
+
+	testee, err := NewClientCredentials(ClientCredentials{
+		TokenUrl:         server.Addr() + "/token",
+		ClientId:         "id",
+		ClientSecretFile: writeFile(t, "secret", "secret"),
+	})
+	assertions.NoError(t, err)
+
+	testee.now = func() time.Time { return time.Now().Add(5 * time.Second) }
+	assertions.StringEqual(t, "refreshed", "Bearer token-2", authorized(t, testee, newRequest(t, "http://host/")))
+}
+
+func TestClientCredentialsNotExpiring(t *testing.T) {
+	server := testserver.StartNewTestServer()
+	defer server.Shutdown()
+	server.ServeOAuth2Tokens("/token", "id", "secret")
+
+	testee, err := NewClientCredentials(ClientCredentials{
+		TokenUrl:         server.Addr() + "/token",
+		ClientId:         "id",
+		ClientSecretFile: writeFile(t, "secret", "secret"),
+	})
+	assertions.NoError(t, err)
+
+	testee.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	assertions.StringEqual(t, "applied", "Bearer token-1", authorized(t, testee, newRequest(t, "http://host/")))
+
+	changed, err := testee.Refresh("host", "Bearer token-1")
+	assertions.NoError(t, err)
+	if !changed {
+		t.Error("expected a new token upon refresh")
+	}
+	assertions.StringEqual(t, "refreshed", "Bearer token-2", authorized(t, testee, newRequest(t, "http://host/")))
+}
+
+func TestClientCredentialsRejected(t *testing.T) {
+	server := testserver.StartNewTestServer()
+	defer server.Shutdown()
+	server.ServeOAuth2Tokens("/token", "id", "secret")
+
+	_, err := NewClientCredentials(ClientCredentials{
+		TokenUrl:         server.Addr() + "/token",
+		ClientId:         "id",
+		ClientSecretFile: writeFile(t, "secret", "wrong"),
+	})
+
+	assertions.ErrorContains(t, "token endpoint responded HTTP 401", err)
+}
+
+func TestClientCredentialsUnsupportedTokenType(t *testing.T) {
+	server := testserver.StartNewTestServer()
+	defer server.Shutdown()
+	server.RegisterHandler("/token", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"access_token": "t", "token_type": "mac"}`))
+	})
+
+	_, err := NewClientCredentials(ClientCredentials{
+		TokenUrl:         server.Addr() + "/token",
+		ClientId:         "id",
+		ClientSecretFile: writeFile(t, "secret", "secret"),
+	})
+
+	assertions.ErrorContains(t, "token type 'mac' isn't supported", err)
+}
+
+func TestClientSecretRedactor(t *testing.T) {
+	testee, err := NewClientSecretRedactor(ClientCredentials{
+		TokenUrl:         "http://unreachable.invalid/token",
+		ClientId:         "id",
+		ClientSecretFile: writeFile(t, "secret", "s3cr&t\n"),
+	})
+	assertions.NoError(t, err)
+
+	assertions.StringEqual(t, "authorized", "", authorized(t, testee, newRequest(t, "http://host/")))
+	assertions.StringEqual(t, "redacted", "secret=***", testee.Redact("secret=s3cr&t"))
+}
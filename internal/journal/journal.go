@@ -0,0 +1,175 @@
+package journal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Journal records the number of input lines completed so far, i.e. the line number such that
+// all the lines up to it, including the skipped and the empty ones, have been processed.
+// With the lines processed in parallel, that is the line before the lowest one still in progress.
+//
+// The file is rewritten atomically and synced to the disk at most once per FlushInterval and upon Close,
+// hence a resumed run might repeat the calls made within the last interval before a crash.
+// With empty Path, the progress is only kept in memory.
+//
+// Journal is safe for concurrent use. A nil Journal records nothing.
+type Journal struct {
+	Path          string
+	FlushInterval time.Duration
+
+	mu          sync.Mutex
+	highestRead int
+	inProgress  map[int]bool
+	written     int
+	writtenAt   time.Time
+}
+
+func New(path string) *Journal {
+	return &Journal{
+		Path:          path,
+		FlushInterval: time.Second,
+		inProgress:    map[int]bool{},
+		written:       -1,
+	}
+}
+
+// Read returns the number of completed lines recorded in the journal file, 0 if there's no such file.
+func Read(path string) (int, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read journal: %w", err)
+	}
+	completed, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || completed < 0 {
+		return 0, fmt.Errorf("journal %s doesn't contain a line number: %q", path, content)
+	}
+	return completed, nil
+}
+
+// Passed marks a line which doesn't need processing, e.g. an empty or skipped one.
+func (j *Journal) Passed(lineNo int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.read(lineNo)
+}
+
+// Started marks a line as taken into processing.
+func (j *Journal) Started(lineNo int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.read(lineNo)
+	j.inProgress[lineNo] = true
+}
+
+// Done marks a started line as processed, flushing the journal if due.
+func (j *Journal) Done(lineNo int) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.inProgress, lineNo)
+	if time.Since(j.writtenAt) < j.FlushInterval {
+		return nil
+	}
+	return j.flush()
+}
+
+// Close flushes the journal.
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.flush()
+}
+
+// Completed is the number of lines completed so far.
+func (j *Journal) Completed() int {
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.completed()
+}
+
+func (j *Journal) read(lineNo int) {
+	if lineNo > j.highestRead {
+		j.highestRead = lineNo
+	}
+}
+
+func (j *Journal) completed() int {
+	result := j.highestRead
+	for lineNo := range j.inProgress {
+		if lineNo-1 < result {
+			result = lineNo - 1
+		}
+	}
+	return result
+}
+
+func (j *Journal) flush() error {
+	completed := j.completed()
+	if completed == j.written || j.Path == "" {
+		return nil
+	}
+
+	tmpPath := j.Path + ".tmp"
+	if err := writeSynced(tmpPath, []byte(strconv.Itoa(completed)+"\n")); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if err := os.Rename(tmpPath, j.Path); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	syncDir(filepath.Dir(j.Path))
+
+	j.written = completed
+	j.writtenAt = time.Now()
+	return nil
+}
+
+// writeSynced is ioutil.WriteFile making sure the content is on the disk before the file is renamed over the journal.
+func writeSynced(path string, content []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir makes the rename durable. Not all the platforms allow syncing a directory, hence it's the best effort.
+func syncDir(path string) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	dir.Sync()
+	dir.Close()
+}
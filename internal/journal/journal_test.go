@@ -0,0 +1,115 @@
+package journal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mgurov/mposter/internal/assertions"
+)
+
+func TestCompletedSequential(t *testing.T) {
+	testee := New("")
+
+	testee.Passed(1)
+	testee.Started(2)
+	assertCompleted(t, 1, testee)
+
+	testee.Done(2)
+	assertCompleted(t, 2, testee)
+
+	testee.Passed(3)
+	assertCompleted(t, 3, testee)
+}
+
+func TestCompletedStopsAtLowestInProgress(t *testing.T) {
+	testee := New("")
+	testee.FlushInterval = 1 << 62 // don't write
+
+	testee.Started(1)
+	testee.Started(2)
+	testee.Passed(3)
+	testee.Started(4)
+
+	testee.Done(2)
+	testee.Done(4)
+	assertCompleted(t, 0, testee)
+
+	testee.Done(1)
+	assertCompleted(t, 4, testee)
+}
+
+func TestWriteAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	assertions.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "run.journal")
+
+	completed, err := Read(path)
+	assertions.NoError(t, err)
+	if completed != 0 {
+		t.Errorf("expected 0 for missing journal, got %d", completed)
+	}
+
+	testee := New(path)
+	testee.Started(1)
+	testee.Started(2)
+	assertions.NoError(t, testee.Done(2))
+	assertions.NoError(t, testee.Close())
+
+	completed, err = Read(path)
+	assertions.NoError(t, err)
+	if completed != 0 {
+		t.Errorf("expected line 1 still in progress, got %d completed", completed)
+	}
+
+	assertions.NoError(t, testee.Done(1))
+	assertions.NoError(t, testee.Close())
+
+	completed, err = Read(path)
+	assertions.NoError(t, err)
+	if completed != 2 {
+		t.Errorf("expected 2 completed, got %d", completed)
+	}
+}
+
+func TestReadGarbage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	assertions.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "run.journal")
+	assertions.NoError(t, ioutil.WriteFile(path, []byte("fooe"), 0644))
+
+	_, err = Read(path)
+	assertions.ErrorContains(t, "doesn't contain a line number", err)
+}
+
+func TestInMemoryJournal(t *testing.T) {
+	testee := New("")
+
+	testee.Started(1)
+	assertions.NoError(t, testee.Done(1))
+	assertions.NoError(t, testee.Close())
+	assertCompleted(t, 1, testee)
+
+	if _, err := os.Stat(".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected no file written without the path, got %v", err)
+	}
+}
+
+func TestNilJournal(t *testing.T) {
+	var testee *Journal
+
+	testee.Passed(1)
+	testee.Started(2)
+	assertions.NoError(t, testee.Done(2))
+	assertions.NoError(t, testee.Close())
+}
+
+func assertCompleted(t *testing.T, expected int, testee *Journal) {
+	t.Helper()
+	if actual := testee.Completed(); actual != expected {
+		t.Errorf("expected %d completed, got %d", expected, actual)
+	}
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Advice is what the server tells about its rate limits in the response headers.
+type Advice struct {
+	RetryAfter time.Duration // to hold the calls for, 0 if not asked
+	Remaining  int           // calls left in the current window, -1 if not told
+	Reset      time.Time     // when the window resets, zero if not told
+}
+
+// epochThreshold tells the reset given as the unix time from the one given in seconds from now.
+const epochThreshold = 1000000000
+
+// ParseAdvice reads the Retry-After header of HTTP 429 and 503 responses and the X-RateLimit-Remaining/Reset,
+// or RateLimit-Remaining/Reset, headers of any response. The reset is either in seconds from now or the unix time.
+func ParseAdvice(status int, header http.Header, now time.Time) Advice {
+	result := Advice{Remaining: -1}
+
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		result.RetryAfter = parseRetryAfter(header.Get("Retry-After"), now)
+	}
+
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(strings.TrimSpace(header.Get(prefix + "Remaining")))
+		if err != nil || remaining < 0 {
+			continue
+		}
+		result.Remaining = remaining
+		if reset, err := strconv.ParseInt(strings.TrimSpace(header.Get(prefix+"Reset")), 10, 64); err == nil && reset >= 0 {
+			if reset >= epochThreshold {
+				result.Reset = time.Unix(reset, 0)
+			} else {
+				result.Reset = now.Add(time.Duration(reset) * time.Second)
+			}
+		}
+		break
+	}
+	return result
+}
+
+// parseRetryAfter understands both the delay in seconds and the http date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
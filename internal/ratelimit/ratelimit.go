@@ -0,0 +1,204 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate is a number of calls allowed per a time unit. Zero Count means no limit.
+type Rate struct {
+	Count int
+	Per   time.Duration
+}
+
+// ParseRate understands `10`, `10/s`, `600/m`, `1000/h`. The unit defaults to a second.
+func ParseRate(input string) (Rate, error) {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return Rate{}, nil
+	}
+
+	countPart, unitPart := trimmed, "s"
+	if slash := strings.Index(trimmed, "/"); slash != -1 {
+		countPart, unitPart = trimmed[:slash], trimmed[slash+1:]
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(countPart))
+	if err != nil || count < 0 {
+		return Rate{}, fmt.Errorf("rate '%s' should start with a non-negative number", input)
+	}
+
+	var per time.Duration
+	switch strings.TrimSpace(unitPart) {
+	case "s", "sec", "second":
+		per = time.Second
+	case "m", "min", "minute":
+		per = time.Minute
+	case "h", "hour":
+		per = time.Hour
+	default:
+		return Rate{}, fmt.Errorf("rate '%s' has unknown unit, expected one of s, m, h", input)
+	}
+
+	return Rate{Count: count, Per: per}, nil
+}
+
+func (r Rate) interval() time.Duration {
+	if r.Count <= 0 {
+		return 0
+	}
+	return r.Per / time.Duration(r.Count)
+}
+
+// Limiter combines a token bucket allowing up to `burst` calls at once refilled at the given rate
+// with an enforced minimal duration between the starts of the consequent calls.
+// On top of that, the calls are held as advised by the server, see Advise.
+// Limiter is safe for concurrent use.
+type Limiter struct {
+	mu sync.Mutex
+
+	interval        time.Duration
+	burst           int
+	minimalDuration time.Duration
+
+	bucketFullAt time.Time // the moment the bucket would be full again; might be in the past
+	nextStart    time.Time // the earliest start of the next call according to the minimal duration
+
+	lowQuota      int       // the remaining calls told by the server to start spreading them evenly till the reset at
+	pausedUntil   time.Time // the calls are held till then as asked by the server
+	quotaInterval time.Duration
+	quotaUntil    time.Time // the quota interval applies till then
+	quotaNext     time.Time // the earliest start of the next call according to the quota interval
+
+	now   func() time.Time
+	sleep func(time.Duration, <-chan struct{}) bool
+}
+
+func New(rate Rate, burst int, minimalDuration time.Duration) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		interval:        rate.interval(),
+		burst:           burst,
+		minimalDuration: minimalDuration,
+		now:             time.Now,
+		sleep:           sleep,
+	}
+}
+
+// sleep tells false if done before the duration has passed.
+func sleep(d time.Duration, done <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// WithLowQuota makes the limiter spread the calls evenly till the reset once the server tells the given number of the calls remaining or less.
+func (l *Limiter) WithLowQuota(lowQuota int) *Limiter {
+	l.lowQuota = lowQuota
+	return l
+}
+
+// Wait blocks until the next call is allowed, also if the run has been paused meanwhile.
+// Returns false if done is closed before that.
+func (l *Limiter) Wait(done <-chan struct{}) bool {
+	if delay := l.reserve(); delay > 0 && !l.sleep(delay, done) {
+		return false
+	}
+	for {
+		l.mu.Lock()
+		delay := l.pausedUntil.Sub(l.now())
+		l.mu.Unlock()
+		if delay <= 0 {
+			return true
+		}
+		if !l.sleep(delay, done) {
+			return false
+		}
+	}
+}
+
+// Advise holds all the calls for the time asked by the server, or till the reset if no calls remain,
+// and spreads the calls till the reset once the remaining ones are at or below the low quota.
+// Returns the time the calls are paused for, 0 if not paused.
+func (l *Limiter) Advise(advice Advice) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	pauseUntil := time.Time{}
+	if advice.RetryAfter > 0 {
+		pauseUntil = now.Add(advice.RetryAfter)
+	}
+
+	if advice.Remaining >= 0 && advice.Reset.After(now) {
+		switch {
+		case advice.Remaining == 0:
+			if advice.Reset.After(pauseUntil) {
+				pauseUntil = advice.Reset
+			}
+		case advice.Remaining <= l.lowQuota:
+			l.quotaInterval = advice.Reset.Sub(now) / time.Duration(advice.Remaining)
+			l.quotaUntil = advice.Reset
+		default:
+			l.quotaInterval = 0
+		}
+	}
+
+	if pauseUntil.After(l.pausedUntil) {
+		l.pausedUntil = pauseUntil
+		return pauseUntil.Sub(now)
+	}
+	return 0
+}
+
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	start := now
+
+	if l.minimalDuration > 0 && l.nextStart.After(start) {
+		start = l.nextStart
+	}
+
+	if l.pausedUntil.After(start) {
+		start = l.pausedUntil
+	}
+
+	if l.quotaInterval > 0 && start.Before(l.quotaUntil) {
+		if l.quotaNext.After(start) {
+			start = l.quotaNext
+		}
+		l.quotaNext = start.Add(l.quotaInterval)
+	}
+
+	if l.interval > 0 {
+		// the bucket is modelled by the moment it would be full again:
+		// a call is allowed as soon as there is room for one more interval within the burst capacity.
+		capacity := time.Duration(l.burst) * l.interval
+		if l.bucketFullAt.Before(start) {
+			l.bucketFullAt = start
+		}
+		if allowedAt := l.bucketFullAt.Add(l.interval - capacity); allowedAt.After(start) {
+			start = allowedAt
+		}
+		l.bucketFullAt = l.bucketFullAt.Add(l.interval)
+	}
+
+	if l.minimalDuration > 0 {
+		l.nextStart = start.Add(l.minimalDuration)
+	}
+
+	return start.Sub(now)
+}
@@ -0,0 +1,253 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mgurov/mposter/internal/assertions"
+)
+
+type fakeClock struct {
+	now    time.Time
+	starts []time.Duration
+	origin time.Time
+}
+
+func withFakeClock(l *Limiter) *fakeClock {
+	clock := &fakeClock{now: time.Unix(1000, 0), origin: time.Unix(1000, 0)}
+	l.now = func() time.Time { return clock.now }
+	l.sleep = func(d time.Duration, done <-chan struct{}) bool {
+		clock.now = clock.now.Add(d)
+		return true
+	}
+	return clock
+}
+
+func (c *fakeClock) call(l *Limiter) {
+	l.Wait(nil)
+	c.starts = append(c.starts, c.now.Sub(c.origin))
+}
+
+func assertStarts(t *testing.T, expected []time.Duration, actual []time.Duration) {
+	if len(expected) != len(actual) {
+		t.Fatalf("expected starts %v got %v", expected, actual)
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("expected starts %v got %v", expected, actual)
+			return
+		}
+	}
+}
+
+func TestNoLimitDoesNotWait(t *testing.T) {
+	l := New(Rate{}, 0, 0)
+	clock := withFakeClock(l)
+
+	for i := 0; i < 3; i++ {
+		clock.call(l)
+	}
+
+	assertStarts(t, []time.Duration{0, 0, 0}, clock.starts)
+}
+
+func TestRateSpacesCalls(t *testing.T) {
+	l := New(Rate{Count: 2, Per: time.Second}, 1, 0)
+	clock := withFakeClock(l)
+
+	for i := 0; i < 3; i++ {
+		clock.call(l)
+	}
+
+	assertStarts(t, []time.Duration{0, 500 * time.Millisecond, time.Second}, clock.starts)
+}
+
+func TestRateBurst(t *testing.T) {
+	l := New(Rate{Count: 1, Per: time.Second}, 3, 0)
+	clock := withFakeClock(l)
+
+	for i := 0; i < 5; i++ {
+		clock.call(l)
+	}
+
+	assertStarts(t, []time.Duration{0, 0, 0, time.Second, 2 * time.Second}, clock.starts)
+}
+
+func TestRateBurstRefillsWhenIdle(t *testing.T) {
+	l := New(Rate{Count: 1, Per: time.Second}, 2, 0)
+	clock := withFakeClock(l)
+
+	clock.call(l)
+	clock.call(l)
+	clock.now = clock.now.Add(10 * time.Second)
+	clock.call(l)
+	clock.call(l)
+	clock.call(l)
+
+	assertStarts(t, []time.Duration{0, 0, 10 * time.Second, 10 * time.Second, 11 * time.Second}, clock.starts)
+}
+
+func TestMinimalDuration(t *testing.T) {
+	l := New(Rate{}, 0, 5*time.Second)
+	clock := withFakeClock(l)
+
+	clock.call(l)
+	clock.now = clock.now.Add(2 * time.Second) // the call took 2 seconds
+	clock.call(l)
+	clock.now = clock.now.Add(7 * time.Second) // the call took longer than the minimal duration
+	clock.call(l)
+
+	assertStarts(t, []time.Duration{0, 5 * time.Second, 12 * time.Second}, clock.starts)
+}
+
+func TestMinimalDurationWinsOverBurst(t *testing.T) {
+	l := New(Rate{Count: 10, Per: time.Second}, 10, time.Second)
+	clock := withFakeClock(l)
+
+	for i := 0; i < 3; i++ {
+		clock.call(l)
+	}
+
+	assertStarts(t, []time.Duration{0, time.Second, 2 * time.Second}, clock.starts)
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Rate
+	}{
+		{input: "", want: Rate{}},
+		{input: "10", want: Rate{Count: 10, Per: time.Second}},
+		{input: "10/s", want: Rate{Count: 10, Per: time.Second}},
+		{input: "600/m", want: Rate{Count: 600, Per: time.Minute}},
+		{input: " 5 / min ", want: Rate{Count: 5, Per: time.Minute}},
+		{input: "1000/h", want: Rate{Count: 1000, Per: time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRate(tt.input)
+			assertions.NoError(t, err)
+			if got != tt.want {
+				t.Errorf("ParseRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRateErr(t *testing.T) {
+	_, err := ParseRate("fast")
+	assertions.ErrorContains(t, "rate 'fast' should start with a non-negative number", err)
+
+	_, err = ParseRate("10/d")
+	assertions.ErrorContains(t, "rate '10/d' has unknown unit", err)
+}
+
+func TestAdvisedPauseHoldsAllCalls(t *testing.T) {
+	l := New(Rate{}, 0, 0)
+	clock := withFakeClock(l)
+
+	clock.call(l)
+	paused := l.Advise(Advice{RetryAfter: 2 * time.Second, Remaining: -1})
+	clock.call(l)
+	clock.call(l)
+
+	if paused != 2*time.Second {
+		t.Errorf("expected paused for 2s, got %v", paused)
+	}
+	assertStarts(t, []time.Duration{0, 2 * time.Second, 2 * time.Second}, clock.starts)
+}
+
+func TestShorterAdvisedPauseDoesNotCutTheLongerOne(t *testing.T) {
+	l := New(Rate{}, 0, 0)
+	clock := withFakeClock(l)
+
+	l.Advise(Advice{RetryAfter: 3 * time.Second, Remaining: -1})
+	if paused := l.Advise(Advice{RetryAfter: time.Second, Remaining: -1}); paused != 0 {
+		t.Errorf("expected no new pause, got %v", paused)
+	}
+	clock.call(l)
+
+	assertStarts(t, []time.Duration{3 * time.Second}, clock.starts)
+}
+
+func TestPauseAdvisedWhileWaitingHoldsTheCall(t *testing.T) {
+	l := New(Rate{Count: 1, Per: time.Second}, 1, 0)
+	clock := withFakeClock(l)
+	sleep := l.sleep
+	l.sleep = func(d time.Duration, done <-chan struct{}) bool {
+		sleep(d, done)
+		if clock.now == clock.origin.Add(time.Second) {
+			l.Advise(Advice{RetryAfter: 5 * time.Second, Remaining: -1})
+		}
+		return true
+	}
+
+	clock.call(l)
+	clock.call(l)
+
+	assertStarts(t, []time.Duration{0, 6 * time.Second}, clock.starts)
+}
+
+func TestWaitReturnsOnceDone(t *testing.T) {
+	l := New(Rate{}, 0, 0)
+	l.Advise(Advice{RetryAfter: time.Hour, Remaining: -1})
+	done := make(chan struct{})
+	close(done)
+
+	if l.Wait(done) {
+		t.Error("expected the wait to be given up")
+	}
+}
+
+func TestExhaustedQuotaPausesTillReset(t *testing.T) {
+	l := New(Rate{}, 0, 0)
+	clock := withFakeClock(l)
+
+	l.Advise(Advice{Remaining: 0, Reset: clock.now.Add(10 * time.Second)})
+	clock.call(l)
+
+	assertStarts(t, []time.Duration{10 * time.Second}, clock.starts)
+}
+
+func TestLowQuotaSpreadsCallsTillReset(t *testing.T) {
+	l := New(Rate{}, 0, 0).WithLowQuota(5)
+	clock := withFakeClock(l)
+
+	l.Advise(Advice{Remaining: 10, Reset: clock.now.Add(8 * time.Second)})
+	clock.call(l)
+	clock.call(l)
+
+	l.Advise(Advice{Remaining: 4, Reset: clock.now.Add(8 * time.Second)})
+	for i := 0; i < 5; i++ {
+		clock.call(l)
+	}
+
+	assertStarts(t, []time.Duration{0, 0, 0, 2 * time.Second, 4 * time.Second, 6 * time.Second, 8 * time.Second}, clock.starts)
+}
+
+func TestParseAdvice(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	header := http.Header{}
+	header.Set("Retry-After", "3")
+	header.Set("X-RateLimit-Remaining", "7")
+	header.Set("X-RateLimit-Reset", "30")
+	assertAdvice(t, "429 relative reset", Advice{RetryAfter: 3 * time.Second, Remaining: 7, Reset: now.Add(30 * time.Second)}, ParseAdvice(429, header, now))
+	assertAdvice(t, "200 ignores retry after", Advice{Remaining: 7, Reset: now.Add(30 * time.Second)}, ParseAdvice(200, header, now))
+
+	header = http.Header{}
+	header.Set("Retry-After", now.Add(time.Minute).UTC().Format(http.TimeFormat))
+	header.Set("RateLimit-Remaining", "0")
+	header.Set("RateLimit-Reset", "1600000042")
+	assertAdvice(t, "503 http date, unix reset", Advice{RetryAfter: time.Minute, Remaining: 0, Reset: time.Unix(1600000042, 0)}, ParseAdvice(503, header, now))
+
+	assertAdvice(t, "none", Advice{Remaining: -1}, ParseAdvice(429, http.Header{}, now))
+}
+
+func assertAdvice(t *testing.T, title string, expected, actual Advice) {
+	t.Helper()
+	if expected.RetryAfter != actual.RetryAfter || expected.Remaining != actual.Remaining || !expected.Reset.Equal(actual.Reset) {
+		t.Errorf("%s: expected %+v, got %+v", title, expected, actual)
+	}
+}
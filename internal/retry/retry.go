@@ -0,0 +1,117 @@
+package retry
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/mgurov/mposter/internal/statuscode"
+)
+
+// ErrClasses lists the transport error classes which can be retried on.
+var ErrClasses = []string{"timeout", "connection-refused", "connection-reset", "dns", "tls"}
+
+// Policy decides whether and when a failed call is to be retried.
+type Policy struct {
+	Retries   int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RateLimitRetries is how many times to retry the calls rejected with Retry-After, on top of the Retries
+	RateLimitRetries int
+
+	statuses   statuscode.Matcher
+	errClasses map[string]bool
+}
+
+// NewPolicy builds a policy from the flag values, e.g. `3`, `100ms/10s`, `5xx,429,timeout`.
+func NewPolicy(retries int, backoff string, retryOn string) (Policy, error) {
+	result := Policy{Retries: retries, errClasses: map[string]bool{}}
+
+	var err error
+	if result.BaseDelay, result.MaxDelay, err = parseBackoff(backoff); err != nil {
+		return Policy{}, err
+	}
+
+	for _, part := range strings.Split(retryOn, ",") {
+		trimmed := strings.ToLower(strings.TrimSpace(part))
+		if trimmed == "" {
+			continue
+		}
+		if isErrClass(trimmed) {
+			result.errClasses[trimmed] = true
+			continue
+		}
+		if err := result.statuses.Add(trimmed); err != nil {
+			return Policy{}, fmt.Errorf("retry condition '%s' isn't recognized, expected http codes like 503 or 5xx or one of %s", part, strings.Join(ErrClasses, ", "))
+		}
+	}
+
+	return result, nil
+}
+
+func isErrClass(candidate string) bool {
+	for _, known := range ErrClasses {
+		if known == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBackoff accepts `base` or `base/max` durations, e.g. `100ms/10s`. Max defaults to 100 times the base.
+func parseBackoff(input string) (base, max time.Duration, err error) {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return 0, 0, nil
+	}
+
+	basePart, maxPart := trimmed, ""
+	if slash := strings.Index(trimmed, "/"); slash != -1 {
+		basePart, maxPart = trimmed[:slash], trimmed[slash+1:]
+	}
+
+	if base, err = time.ParseDuration(strings.TrimSpace(basePart)); err != nil {
+		return 0, 0, fmt.Errorf("retry backoff '%s': %w", input, err)
+	}
+
+	if maxPart == "" {
+		return base, 100 * base, nil
+	}
+
+	if max, err = time.ParseDuration(strings.TrimSpace(maxPart)); err != nil {
+		return 0, 0, fmt.Errorf("retry backoff '%s': %w", input, err)
+	}
+	if max < base {
+		return 0, 0, fmt.Errorf("retry backoff '%s': max shouldn't be less than the base", input)
+	}
+	return base, max, nil
+}
+
+// ShouldRetry tells whether the attempt number `attempt` (starting with 1) failed with either http status or error class is to be retried.
+func (p Policy) ShouldRetry(attempt int, status int, errClass string) bool {
+	if attempt > p.Retries {
+		return false
+	}
+	if errClass != "" {
+		return p.errClasses[errClass]
+	}
+	return p.statuses.Matches(status)
+}
+
+// Delay is the pause before the next attempt after the given failed one (starting with 1):
+// the base delay doubled on each attempt, capped by the max delay, half of which randomized.
+func (p Policy) Delay(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,90 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mgurov/mposter/internal/assertions"
+)
+
+func TestShouldRetry(t *testing.T) {
+	policy, err := NewPolicy(2, "", "5xx,429,timeout")
+	assertions.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		attempt  int
+		status   int
+		errClass string
+		want     bool
+	}{
+		{name: "5xx", attempt: 1, status: 503, want: true},
+		{name: "429", attempt: 1, status: 429, want: true},
+		{name: "404", attempt: 1, status: 404, want: false},
+		{name: "timeout", attempt: 1, errClass: "timeout", want: true},
+		{name: "connection refused not configured", attempt: 1, errClass: "connection-refused", want: false},
+		{name: "last retry", attempt: 2, status: 503, want: true},
+		{name: "retries exhausted", attempt: 3, status: 503, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.ShouldRetry(tt.attempt, tt.status, tt.errClass); got != tt.want {
+				t.Errorf("ShouldRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldNotRetryByDefault(t *testing.T) {
+	policy, err := NewPolicy(0, "", "5xx")
+	assertions.NoError(t, err)
+
+	if policy.ShouldRetry(1, 500, "") {
+		t.Error("expected no retry with 0 retries")
+	}
+}
+
+func TestDelay(t *testing.T) {
+	policy, err := NewPolicy(10, "100ms/1s", "")
+	assertions.NoError(t, err)
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 100 * time.Millisecond},
+		{attempt: 2, max: 200 * time.Millisecond},
+		{attempt: 3, max: 400 * time.Millisecond},
+		{attempt: 4, max: 800 * time.Millisecond},
+		{attempt: 5, max: time.Second},
+		{attempt: 10, max: time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := policy.Delay(tt.attempt)
+			if got < tt.max/2 || got > tt.max {
+				t.Errorf("Delay(%d) = %s, want between %s and %s", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestNoDelayByDefault(t *testing.T) {
+	policy, err := NewPolicy(1, "", "")
+	assertions.NoError(t, err)
+
+	if got := policy.Delay(1); got != 0 {
+		t.Errorf("Delay() = %s, want 0", got)
+	}
+}
+
+func TestNewPolicyErr(t *testing.T) {
+	_, err := NewPolicy(1, "fooe", "")
+	assertions.ErrorContains(t, "retry backoff 'fooe'", err)
+
+	_, err = NewPolicy(1, "1s/100ms", "")
+	assertions.ErrorContains(t, "max shouldn't be less than the base", err)
+
+	_, err = NewPolicy(1, "", "5xx,sometimes")
+	assertions.ErrorContains(t, "retry condition 'sometimes' isn't recognized", err)
+}
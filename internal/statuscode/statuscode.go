@@ -0,0 +1,59 @@
+package statuscode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Matcher tells whether an http status code is in the list of exact codes or code classes, e.g. `401,403,5xx`.
+// The zero value matches nothing.
+type Matcher struct {
+	codes   map[int]bool
+	classes map[int]bool
+}
+
+// Parse accepts a comma separated list of exact codes (`404`) and classes (`4xx`).
+func Parse(input string) (Matcher, error) {
+	result := Matcher{}
+	for _, part := range strings.Split(input, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		if err := result.Add(part); err != nil {
+			return Matcher{}, err
+		}
+	}
+	return result, nil
+}
+
+// Add includes a single code (`404`) or a code class (`4xx`) into the matcher.
+func (m *Matcher) Add(pattern string) error {
+	trimmed := strings.ToLower(strings.TrimSpace(pattern))
+
+	if len(trimmed) == 3 && strings.HasSuffix(trimmed, "xx") {
+		class, err := strconv.Atoi(trimmed[:1])
+		if err != nil || class < 1 {
+			return fmt.Errorf("http code class '%s' isn't recognized", pattern)
+		}
+		if m.classes == nil {
+			m.classes = map[int]bool{}
+		}
+		m.classes[class] = true
+		return nil
+	}
+
+	code, err := strconv.Atoi(trimmed)
+	if err != nil || code < 100 || code > 999 {
+		return fmt.Errorf("http code '%s' isn't recognized", pattern)
+	}
+	if m.codes == nil {
+		m.codes = map[int]bool{}
+	}
+	m.codes[code] = true
+	return nil
+}
+
+func (m Matcher) Matches(code int) bool {
+	return m.codes[code] || m.classes[code/100]
+}
@@ -0,0 +1,52 @@
+package statuscode
+
+import (
+	"testing"
+
+	"github.com/mgurov/mposter/internal/assertions"
+)
+
+func TestMatches(t *testing.T) {
+	matcher, err := Parse("401, 403,5xx")
+	assertions.NoError(t, err)
+
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{code: 401, want: true},
+		{code: 403, want: true},
+		{code: 404, want: false},
+		{code: 500, want: true},
+		{code: 503, want: true},
+		{code: 200, want: false},
+	}
+	for _, tt := range tests {
+		if got := matcher.Matches(tt.code); got != tt.want {
+			t.Errorf("Matches(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	matcher, err := Parse("")
+	assertions.NoError(t, err)
+
+	if matcher.Matches(500) || matcher.Matches(200) {
+		t.Error("expected empty matcher to match nothing")
+	}
+	if (Matcher{}).Matches(500) {
+		t.Error("expected zero matcher to match nothing")
+	}
+}
+
+func TestParseErr(t *testing.T) {
+	_, err := Parse("4xx,fooe")
+	assertions.ErrorContains(t, "http code 'fooe' isn't recognized", err)
+
+	_, err = Parse("0xx")
+	assertions.ErrorContains(t, "http code class '0xx' isn't recognized", err)
+
+	_, err = Parse("42")
+	assertions.ErrorContains(t, "http code '42' isn't recognized", err)
+}
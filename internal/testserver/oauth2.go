@@ -0,0 +1,85 @@
+package testserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// OAuth2TokenEndpoint issues the client credentials tokens numbered in sequence, token-1, token-2 and so on, the latest one being the only valid.
+type OAuth2TokenEndpoint struct {
+	ClientId     string
+	ClientSecret string
+	ExpiresIn    int // seconds, not told if 0
+
+	mu      sync.Mutex
+	issued  int
+	revoked bool
+}
+
+// ServeOAuth2Tokens registers the token endpoint at the path.
+func (s *TestServer) ServeOAuth2Tokens(path, clientId, clientSecret string) *OAuth2TokenEndpoint {
+	endpoint := &OAuth2TokenEndpoint{ClientId: clientId, ClientSecret: clientSecret}
+	s.RegisterHandler(path, endpoint.ServeHTTP)
+	return endpoint
+}
+
+func (e *OAuth2TokenEndpoint) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// form encoded first, as per RFC 6749 2.3.1
+	encodedId, encodedSecret, ok := req.BasicAuth()
+	clientId, _ := url.QueryUnescape(encodedId)
+	clientSecret, _ := url.QueryUnescape(encodedSecret)
+	if !ok || clientId != e.ClientId || clientSecret != e.ClientSecret {
+		w.WriteHeader(401)
+		return
+	}
+	if req.Method != "POST" || req.FormValue("grant_type") != "client_credentials" {
+		w.WriteHeader(400)
+		return
+	}
+
+	e.mu.Lock()
+	e.issued++
+	e.revoked = false
+	response := map[string]interface{}{
+		"access_token": fmt.Sprint("token-", e.issued),
+		"token_type":   "Bearer",
+	}
+	if e.ExpiresIn > 0 {
+		response["expires_in"] = e.ExpiresIn
+	}
+	e.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Issued tells the number of tokens issued so far.
+func (e *OAuth2TokenEndpoint) Issued() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.issued
+}
+
+// Revoke invalidates the latest token, till a new one is issued.
+func (e *OAuth2TokenEndpoint) Revoke() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.revoked = true
+}
+
+// Protect responds HTTP 401 unless the request bears the latest token issued.
+func (e *OAuth2TokenEndpoint) Protect(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		e.mu.Lock()
+		valid := !e.revoked && e.issued > 0 && req.Header.Get("Authorization") == fmt.Sprint("Bearer token-", e.issued)
+		e.mu.Unlock()
+		if !valid {
+			w.WriteHeader(401)
+			return
+		}
+		handler(w, req)
+	}
+}
@@ -0,0 +1,63 @@
+package testserver
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit lets up to Limit calls through per Window, the first one starting the window, rejecting the rest with HTTP 429
+// and Retry-After till the window ends. The responses tell the calls remaining and the seconds till the reset
+// in X-RateLimit-Remaining and X-RateLimit-Reset.
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+
+	mu        sync.Mutex
+	windowEnd time.Time
+	used      int
+	rejected  int
+}
+
+func NewRateLimit(limit int, window time.Duration) *RateLimit {
+	return &RateLimit{Limit: limit, Window: window}
+}
+
+// Protect lets the calls within the limit through to the handler.
+func (l *RateLimit) Protect(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		l.mu.Lock()
+		now := time.Now()
+		if !now.Before(l.windowEnd) {
+			l.windowEnd = now.Add(l.Window)
+			l.used = 0
+		}
+		allowed := l.used < l.Limit
+		if allowed {
+			l.used++
+		} else {
+			l.rejected++
+		}
+		remaining := l.Limit - l.used
+		reset := strconv.Itoa(int((l.windowEnd.Sub(now) + time.Second - 1) / time.Second))
+		l.mu.Unlock()
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", reset)
+		if !allowed {
+			w.Header().Set("Retry-After", reset)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		handler(w, req)
+	}
+}
+
+// Rejected tells the number of the calls rejected so far.
+func (l *RateLimit) Rejected() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rejected
+}
@@ -3,20 +3,25 @@ package testserver
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"sync"
 )
 
 type testResponseHandler struct {
 	PathToHandler map[string]func(http.ResponseWriter, *http.Request)
+	Fallback      func(http.ResponseWriter, *http.Request)
 }
 
 func (s *testResponseHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	handler, ok := s.PathToHandler[req.URL.Path]
 	if ok {
 		handler(w, req)
+	} else if s.Fallback != nil {
+		s.Fallback(w, req)
 	} else {
 		w.WriteHeader(204)
 	}
@@ -29,13 +34,15 @@ func MakeEmptyResponseHandler(httpStatus int) func(http.ResponseWriter, *http.Re
 }
 
 type loggingHandler struct {
-	//TODO: add mutexes
+	mu   sync.Mutex
 	log  bytes.Buffer
 	next http.Handler
 }
 
 func (s *loggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
 	fmt.Fprintf(&s.log, "%s %s\n", req.Method, req.URL)
+	s.mu.Unlock()
 	log.Printf("%s %s\n", req.Method, req.URL)
 	s.next.ServeHTTP(w, req)
 }
@@ -59,6 +66,11 @@ func NewTestServer() TestServer {
 type TestServer struct {
 	Binding       string
 	PathToHandler map[string]func(http.ResponseWriter, *http.Request)
+	Fallback      func(http.ResponseWriter, *http.Request) // for the paths not registered, 204 if nil
+	// TLS makes the server serve https with the server certificate given, plain http if nil
+	TLS *TestCertificates
+	// RequireClientCert makes the TLS server only accept the clients with the certificates issued by the TLS CA
+	RequireClientCert bool
 
 	server  *http.Server
 	addr    *net.TCPAddr
@@ -67,7 +79,7 @@ type TestServer struct {
 
 func (s *TestServer) Start() {
 	s.handler = &loggingHandler{
-		next: &testResponseHandler{s.PathToHandler},
+		next: &testResponseHandler{s.PathToHandler, s.Fallback},
 	}
 
 	//TODO: does listener have to be stopped explicitly to free up the port?
@@ -76,6 +88,9 @@ func (s *TestServer) Start() {
 		log.Fatalf("Couldn't start test server on a binding %s, %q", s.Binding, err)
 	}
 	s.addr = listener.Addr().(*net.TCPAddr)
+	if s.TLS != nil {
+		listener = tls.NewListener(listener, s.TLS.serverConfig(s.RequireClientCert))
+	}
 
 	s.server = &http.Server{
 		Handler: s.handler,
@@ -84,10 +99,15 @@ func (s *TestServer) Start() {
 }
 
 func (s TestServer) Addr() string {
+	if s.TLS != nil {
+		return fmt.Sprintf("https://localhost:%d", s.addr.Port)
+	}
 	return fmt.Sprintf("http://localhost:%d", s.addr.Port)
 }
 
 func (s TestServer) AccessLog() string {
+	s.handler.mu.Lock()
+	defer s.handler.mu.Unlock()
 	return s.handler.log.String()
 }
 
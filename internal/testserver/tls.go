@@ -0,0 +1,130 @@
+package testserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+// TestCertificates are a generated CA along with the server and the client certificates issued by it, written as PEM files.
+type TestCertificates struct {
+	CaCertFile     string
+	ServerCertFile string
+	ServerKeyFile  string
+	ClientCertFile string
+	ClientKeyFile  string
+
+	pool       *x509.CertPool
+	serverCert tls.Certificate
+}
+
+// GenerateCertificates writes the certificates into the dir, the server one issued for the server name,
+// also for 127.0.0.1 and ::1 if the name is localhost.
+func GenerateCertificates(dir, serverName string) (*TestCertificates, error) {
+	result := &TestCertificates{
+		CaCertFile:     filepath.Join(dir, "ca.pem"),
+		ServerCertFile: filepath.Join(dir, "server.pem"),
+		ServerKeyFile:  filepath.Join(dir, "server-key.pem"),
+		ClientCertFile: filepath.Join(dir, "client.pem"),
+		ClientKeyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+
+	caTemplate := certificateTemplate(1, "mposter test CA")
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	caKey, caCert, err := issue(caTemplate, nil, nil, result.CaCertFile, "")
+	if err != nil {
+		return nil, err
+	}
+	result.pool = x509.NewCertPool()
+	result.pool.AddCert(caCert)
+
+	serverTemplate := certificateTemplate(2, serverName)
+	serverTemplate.DNSNames = []string{serverName}
+	if serverName == "localhost" {
+		serverTemplate.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	}
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	if _, _, err := issue(serverTemplate, caTemplate, caKey, result.ServerCertFile, result.ServerKeyFile); err != nil {
+		return nil, err
+	}
+	if result.serverCert, err = tls.LoadX509KeyPair(result.ServerCertFile, result.ServerKeyFile); err != nil {
+		return nil, err
+	}
+
+	clientTemplate := certificateTemplate(3, "mposter test client")
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	if _, _, err := issue(clientTemplate, caTemplate, caKey, result.ClientCertFile, result.ClientKeyFile); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func certificateTemplate(serial int64, commonName string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+// issue signs the certificate by the parent, self-signed if nil, writing the certificate and, if keyFile is given, the key.
+func issue(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, certFile, keyFile string) (*ecdsa.PrivateKey, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create certificate: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	if err := writePem(certFile, "CERTIFICATE", der); err != nil {
+		return nil, nil, err
+	}
+
+	if keyFile != "" {
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshal key: %w", err)
+		}
+		if err := writePem(keyFile, "EC PRIVATE KEY", keyDer); err != nil {
+			return nil, nil, err
+		}
+	}
+	return key, certificate, nil
+}
+
+func writePem(path, blockType string, der []byte) error {
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+}
+
+func (c *TestCertificates) serverConfig(requireClientCert bool) *tls.Config {
+	config := &tls.Config{Certificates: []tls.Certificate{c.serverCert}}
+	if requireClientCert {
+		config.ClientCAs = c.pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config
+}
@@ -0,0 +1,57 @@
+package tracker
+
+import (
+	"math"
+	"time"
+)
+
+// stepsPerDoubling is the resolution of the histogram the percentiles are estimated from, about 9% here.
+const stepsPerDoubling = 8
+
+// histogram counts the latencies into the exponentially growing steps starting at a microsecond,
+// hence takes the same memory regardless of the number of the calls.
+type histogram struct {
+	steps []int // counts by step, see stepOf
+	count int
+	max   time.Duration
+}
+
+func stepOf(latency time.Duration) int {
+	if latency <= time.Microsecond {
+		return 0
+	}
+	return int(math.Ceil(math.Log2(float64(latency)/float64(time.Microsecond)) * stepsPerDoubling))
+}
+
+func stepUpperBound(step int) time.Duration {
+	return time.Duration(float64(time.Microsecond) * math.Exp2(float64(step)/stepsPerDoubling))
+}
+
+func (h *histogram) add(latency time.Duration) {
+	step := stepOf(latency)
+	for len(h.steps) <= step {
+		h.steps = append(h.steps, 0)
+	}
+	h.steps[step]++
+
+	h.count++
+	if latency > h.max {
+		h.max = latency
+	}
+}
+
+// percentile estimates the latency the given fraction of the calls completed within, at most the max one.
+func (h histogram) percentile(fraction float64) time.Duration {
+	rank := int(math.Ceil(fraction * float64(h.count)))
+	seen := 0
+	for step, count := range h.steps {
+		seen += count
+		if seen >= rank && seen > 0 {
+			if bound := stepUpperBound(step); bound < h.max {
+				return bound
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
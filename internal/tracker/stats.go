@@ -0,0 +1,189 @@
+package tracker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sample is the outcome of a row to account for in the statistics.
+type Sample struct {
+	Called     bool          // false for the rows which couldn't be called
+	StatusCode int           // 0 if no response received
+	ErrClass   string        // empty if the response is received
+	Latency    time.Duration // of the final attempt
+}
+
+// Stats is the snapshot of the run statistics.
+type Stats struct {
+	Rows        int // completed, Ok, Err and SkipOutcome
+	Ok          int
+	Err         int
+	SkipOutcome int // rows completed with the SKIP outcome, e.g. upon --skip-codes, see Tracker.SkipOutcome
+	Read        int // rows read to be processed
+	Skipped     int // lines skipped by --skip or --resume
+	TotalLines  int // 0 if unknown
+	LinesDone   int // input lines passed, but the ones of the rows still in progress
+	ByStatus    map[int]int
+	ByErrClass  map[string]int
+	Latency     LatencyStats
+	Elapsed     time.Duration // since Start, 0 if not started
+	Throughput  float64       // completed rows per second, 0 if not started
+	ETA         time.Duration // 0 if unknown
+	StopReason  error
+}
+
+// LatencyStats describe the latencies of the calls made.
+type LatencyStats struct {
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Start marks the beginning of the run for the throughput and the ETA to be counted from.
+func (t *Tracker) Start() {
+	t.started = t.clock()
+}
+
+// Read records a row read from the input at the line number given to be processed.
+func (t *Tracker) Read(lineNo int) {
+	t.readCount++
+	t.lastLineRead = lineNo
+}
+
+// Skipped records an input line skipped as already processed.
+func (t *Tracker) Skipped(lineNo int) {
+	t.skippedCount++
+	t.lastLineRead = lineNo
+}
+
+// Observe accounts for the row outcome in the statistics. To be called before Ok or Err for the tick to include it.
+func (t *Tracker) Observe(sample Sample) {
+	if sample.Called {
+		t.latency.add(sample.Latency)
+	}
+	if sample.StatusCode != 0 {
+		if t.byStatus == nil {
+			t.byStatus = map[int]int{}
+		}
+		t.byStatus[sample.StatusCode]++
+	}
+	if sample.ErrClass != "" {
+		if t.byErrClass == nil {
+			t.byErrClass = map[string]int{}
+		}
+		t.byErrClass[sample.ErrClass]++
+	}
+}
+
+func (t Tracker) clock() time.Time {
+	if t.now == nil {
+		return time.Now()
+	}
+	return t.now()
+}
+
+// Stats returns the statistics collected so far.
+func (t Tracker) Stats() Stats {
+	result := Stats{
+		Rows:        t.rowNo,
+		Ok:          t.okCount,
+		Err:         t.errCount,
+		SkipOutcome: t.skipOutcomeCount,
+		Read:        t.readCount,
+		Skipped:     t.skippedCount,
+		TotalLines:  t.TotalLines,
+		LinesDone:   t.lastLineRead - (t.readCount - t.rowNo),
+		ByStatus:    map[int]int{},
+		ByErrClass:  map[string]int{},
+		StopReason:  t.stopReason,
+		Latency: LatencyStats{
+			Count: t.latency.count,
+			P50:   t.latency.percentile(0.5),
+			P90:   t.latency.percentile(0.9),
+			P99:   t.latency.percentile(0.99),
+			Max:   t.latency.max,
+		},
+	}
+	for status, count := range t.byStatus {
+		result.ByStatus[status] = count
+	}
+	for class, count := range t.byErrClass {
+		result.ByErrClass[class] = count
+	}
+
+	if !t.started.IsZero() {
+		result.Elapsed = t.clock().Sub(t.started)
+	}
+	if result.Elapsed > 0 {
+		result.Throughput = float64(t.rowNo) / result.Elapsed.Seconds()
+	}
+	if t.TotalLines > 0 && result.Throughput > 0 {
+		remaining := t.TotalLines - result.LinesDone
+		if remaining < 0 {
+			remaining = 0
+		}
+		result.ETA = time.Duration(float64(remaining) / result.Throughput * float64(time.Second))
+	}
+	return result
+}
+
+// summary tells the throughput, the latencies, the breakdown by the status and the error class and the ETA, skipping the ones unknown yet.
+func (s Stats) summary() string {
+	var parts []string
+	if s.Throughput > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f req/s", s.Throughput))
+	}
+	if s.Latency.Count > 0 {
+		parts = append(parts, fmt.Sprintf("latency p50 %s p90 %s p99 %s max %s",
+			roundDuration(s.Latency.P50), roundDuration(s.Latency.P90), roundDuration(s.Latency.P99), roundDuration(s.Latency.Max)))
+	}
+	if len(s.ByStatus) > 0 {
+		var statuses []int
+		for status := range s.ByStatus {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		var counts []string
+		for _, status := range statuses {
+			counts = append(counts, fmt.Sprintf("%d: %d", status, s.ByStatus[status]))
+		}
+		parts = append(parts, "status "+strings.Join(counts, " "))
+	}
+	if len(s.ByErrClass) > 0 {
+		var classes []string
+		for class := range s.ByErrClass {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		var counts []string
+		for _, class := range classes {
+			counts = append(counts, fmt.Sprintf("%s: %d", class, s.ByErrClass[class]))
+		}
+		parts = append(parts, "errors "+strings.Join(counts, " "))
+	}
+	if s.ETA > 0 {
+		parts = append(parts, "ETA "+roundDuration(s.ETA).String())
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(parts, ", ")
+}
+
+// roundDuration keeps 3 significant digits or so, e.g. 12.3ms or 1m23s.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second)
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
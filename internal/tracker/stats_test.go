@@ -0,0 +1,99 @@
+package tracker
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/mgurov/mposter/internal/assertions"
+)
+
+func Test_LogSummary(t *testing.T) {
+
+	capturedOutput := bytes.Buffer{}
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testee := Tracker{
+		Logger:     log.New(&capturedOutput, "", 0),
+		TickLog:    2,
+		TotalLines: 6,
+		now:        func() time.Time { return clock },
+	}
+	testee.Start()
+
+	//when
+	for lineNo, sample := range []Sample{
+		{Called: true, StatusCode: 200, Latency: 10 * time.Millisecond},
+		{Called: true, ErrClass: "timeout", Latency: time.Second},
+		{Called: true, StatusCode: 500, Latency: 20 * time.Millisecond},
+		{ErrClass: "row"},
+	} {
+		testee.Read(lineNo + 1)
+		clock = clock.Add(time.Second)
+		testee.Observe(sample)
+		if sample.StatusCode == 200 {
+			testee.Ok()
+		} else {
+			testee.Err()
+		}
+	}
+	testee.LogDone()
+
+	expectedOutput := `2 ERR: 1, 1.0 req/s, latency p50 10.62ms p90 1s p99 1s max 1s, status 200: 1, errors timeout: 1, ETA 4s
+4 ERR: 3, 1.0 req/s, latency p50 21.25ms p90 1s p99 1s max 1s, status 200: 1 500: 1, errors row: 1 timeout: 1, ETA 2s
+Done 4 OK: 1 ERR: 3, 1.0 req/s, latency p50 21.25ms p90 1s p99 1s max 1s, status 200: 1 500: 1, errors row: 1 timeout: 1, ETA 2s
+`
+	assertions.StringEqual(t, "", expectedOutput, capturedOutput.String())
+}
+
+func Test_Stats(t *testing.T) {
+
+	testee := Tracker{}
+
+	testee.Skipped(1)
+	testee.Read(2)
+	testee.Read(3)
+	testee.Observe(Sample{Called: true, StatusCode: 204, Latency: 3 * time.Millisecond})
+	testee.Ok()
+
+	stats := testee.Stats()
+	if stats.Rows != 1 || stats.Ok != 1 || stats.Read != 2 || stats.Skipped != 1 {
+		t.Errorf("unexpected counts %+v", stats)
+	}
+	if stats.Throughput != 0 || stats.ETA != 0 {
+		t.Errorf("expected no throughput nor ETA without start and total, got %+v", stats)
+	}
+	if stats.Latency.Count != 1 || stats.Latency.Max != 3*time.Millisecond {
+		t.Errorf("unexpected latency %+v", stats.Latency)
+	}
+}
+
+func Test_Percentiles(t *testing.T) {
+
+	testee := histogram{}
+	for i := 1; i <= 1000; i++ {
+		testee.add(time.Duration(i) * time.Millisecond)
+	}
+
+	for _, tt := range []struct {
+		fraction float64
+		expected time.Duration
+	}{
+		{0.5, 500 * time.Millisecond},
+		{0.9, 900 * time.Millisecond},
+		{0.99, 990 * time.Millisecond},
+		{1, time.Second},
+	} {
+		actual := testee.percentile(tt.fraction)
+		if actual < tt.expected || float64(actual) > float64(tt.expected)*1.1 {
+			t.Errorf("p%v expected within 10%% above %v, got %v", tt.fraction*100, tt.expected, actual)
+		}
+	}
+}
+
+func Test_PercentilesOfNone(t *testing.T) {
+	if actual := (histogram{}).percentile(0.5); actual != 0 {
+		t.Errorf("expected 0, got %v", actual)
+	}
+}
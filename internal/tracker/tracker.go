@@ -3,6 +3,9 @@ package tracker
 import (
 	"fmt"
 	"log"
+	"time"
+
+	"github.com/mgurov/mposter/internal/statuscode"
 )
 
 //Tracker is *not* thread-safe.
@@ -10,26 +13,100 @@ type Tracker struct {
 	rowNo                     int
 	errCount                  int
 	okCount                   int
+	skipOutcomeCount          int
 	consecutiveErrCount       int
+	consecutiveCallErrCount   int //as consecutiveErrCount, but the row errors, for the circuit to open on
 	StopOnFirstErr            bool
 	StopOnConsecutiveErrCount int
 	Logger                    *log.Logger
 	TickLog                   int //number of messages to log the current status at
 	LogFirstErr               bool
+	CircuitOpenOnCount        int //number of consecutive errors to open the circuit at, 0 to disable
+	CircuitMaxOpens           int //number of times the circuit may open before bailing out, 0 for no limit
+	StopOnHttpCode            statuscode.Matcher
+	circuitState              CircuitState
+	circuitOpens              int
+	probe                     int //line number of the probe call while the circuit is half-open
+	stopReason                error
+	TotalLines                int //number of the input lines to estimate the remaining time with, 0 if unknown
+	started                   time.Time
+	now                       func() time.Time
+	readCount                 int
+	skippedCount              int
+	lastLineRead              int
+	byStatus                  map[int]int
+	byErrClass                map[string]int
+	latency                   histogram
+}
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
 }
 
 func (t *Tracker) Ok() {
-	t.rowNo++
 	t.okCount++
+	t.succeeded()
+}
+
+// SkipOutcome records a row found needing nothing done, e.g. already done. Like Ok it breaks the consecutive errors.
+func (t *Tracker) SkipOutcome() {
+	t.skipOutcomeCount++
+	t.succeeded()
+}
+
+func (t *Tracker) succeeded() {
+	t.rowNo++
 	t.consecutiveErrCount = 0
+	t.consecutiveCallErrCount = 0
 	t.maybeLogStatus(false)
 }
 
 // Err returns reason to bail out if such
 func (t *Tracker) Err() error {
+	return t.recordStop(t.err(true))
+}
+
+// RowErr is Err for a row which couldn't be called, e.g. lacking a placeholder value, the row describing the input and the error.
+// It doesn't count towards opening the circuit.
+func (t *Tracker) RowErr(row string) error {
+	bailoutErr := t.err(false)
+	if t.StopOnFirstErr && t.rowNo == 1 {
+		bailoutErr = fmt.Errorf("row error on first row at %s", row)
+	}
+	return t.recordStop(bailoutErr)
+}
+
+// HttpErr is Err for a non-2xx http response. Bails out at once if the status matches StopOnHttpCode, the row describing the input being reported then.
+func (t *Tracker) HttpErr(status int, row string) error {
+	bailoutErr := t.err(true)
+	if t.StopOnHttpCode.Matches(status) {
+		bailoutErr = fmt.Errorf("stop on HTTP %d at %s", status, row)
+	}
+	return t.recordStop(bailoutErr)
+}
+
+func (t *Tracker) err(called bool) error {
 	t.rowNo++
 	t.errCount++
 	t.consecutiveErrCount++
+	if called {
+		t.consecutiveCallErrCount++
+	}
 	t.maybeLogStatus(t.errCount == 1)
 
 	if t.StopOnFirstErr && t.rowNo == 1 {
@@ -38,9 +115,77 @@ func (t *Tracker) Err() error {
 	if t.StopOnConsecutiveErrCount > 0 && t.consecutiveErrCount >= t.StopOnConsecutiveErrCount {
 		return fmt.Errorf("%d consecutive errors", t.consecutiveErrCount)
 	}
+	return t.maybeOpenCircuit()
+}
+
+// Stop records a reason to stop the run coming from outside of the tracker, e.g. an interruption. The first reason recorded is reported.
+func (t *Tracker) Stop(reason error) {
+	t.recordStop(reason)
+}
+
+func (t *Tracker) recordStop(bailoutErr error) error {
+	if bailoutErr != nil && t.stopReason == nil {
+		t.stopReason = bailoutErr
+	}
+	return bailoutErr
+}
+
+// maybeOpenCircuit opens the closed circuit upon CircuitOpenOnCount consecutive call errors.
+// Once half-open, only the probe result counts, see CircuitProbed.
+func (t *Tracker) maybeOpenCircuit() error {
+	if t.circuitState != CircuitClosed || t.CircuitOpenOnCount <= 0 || t.consecutiveCallErrCount < t.CircuitOpenOnCount {
+		return nil
+	}
+	t.logf("circuit open: %d consecutive errors", t.consecutiveCallErrCount)
+	return t.openCircuit()
+}
+
+func (t *Tracker) openCircuit() error {
+	t.circuitState = CircuitOpen
+	t.circuitOpens++
+	if t.CircuitMaxOpens > 0 && t.circuitOpens > t.CircuitMaxOpens {
+		return fmt.Errorf("circuit opened %d times", t.circuitOpens)
+	}
+	return nil
+}
+
+// CircuitState tells whether the calls can be made (closed), should be paused (open) or a single probe call is awaited (half-open).
+func (t Tracker) CircuitState() CircuitState {
+	return t.circuitState
+}
+
+// HalfOpenCircuit is to be called before making the probe call for the line given after the circuit has been open for a while.
+func (t *Tracker) HalfOpenCircuit(probe int) {
+	if t.circuitState == CircuitOpen {
+		t.circuitState = CircuitHalfOpen
+		t.probe = probe
+		t.logf("circuit half-open: probing")
+	}
+}
+
+// CircuitProbed closes the half-open circuit if the call for the line given is the probe one and succeeded, opens it again if failed.
+// The results of the other calls, e.g. the ones in flight since before the circuit opened, are ignored.
+func (t *Tracker) CircuitProbed(lineNo int, ok bool) error {
+	if t.circuitState != CircuitHalfOpen || lineNo != t.probe {
+		return nil
+	}
+	t.probe = 0
+	if !ok {
+		t.logf("circuit open: probe failed")
+		return t.recordStop(t.openCircuit())
+	}
+	t.circuitState = CircuitClosed
+	t.consecutiveCallErrCount = 0
+	t.logf("circuit closed: probe succeeded")
 	return nil
 }
 
+func (t Tracker) logf(format string, v ...interface{}) {
+	if nil != t.Logger {
+		t.Logger.Printf(format, v...)
+	}
+}
+
 func (t Tracker) maybeLogStatus(firstErr bool) {
 	if (t.TickLog > 0 && t.rowNo%t.TickLog == 0) || (firstErr && t.LogFirstErr) {
 		t.LogStatus()
@@ -49,12 +194,25 @@ func (t Tracker) maybeLogStatus(firstErr bool) {
 
 func (t Tracker) LogStatus() {
 	if nil != t.Logger {
-		t.Logger.Printf("%d ERR: %d", t.rowNo, t.errCount)
+		t.Logger.Printf("%d ERR: %d%s%s", t.rowNo, t.errCount, t.skipOutcomeStatus(), t.Stats().summary())
 	}
 }
 
 func (t Tracker) LogDone() {
-	if nil != t.Logger {
-		t.Logger.Printf("Done %d OK: %d ERR: %d", t.rowNo, t.okCount, t.errCount)
+	if nil == t.Logger {
+		return
+	}
+	if nil != t.stopReason {
+		t.Logger.Printf("Done %d OK: %d ERR: %d%s%s Stopped: %s", t.rowNo, t.okCount, t.errCount, t.skipOutcomeStatus(), t.Stats().summary(), t.stopReason)
+	} else {
+		t.Logger.Printf("Done %d OK: %d ERR: %d%s%s", t.rowNo, t.okCount, t.errCount, t.skipOutcomeStatus(), t.Stats().summary())
+	}
+}
+
+// skipOutcomeStatus is the count of the skip outcomes to log, empty if none.
+func (t Tracker) skipOutcomeStatus() string {
+	if t.skipOutcomeCount == 0 {
+		return ""
 	}
+	return fmt.Sprintf(" SKIP: %d", t.skipOutcomeCount)
 }
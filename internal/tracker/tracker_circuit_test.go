@@ -0,0 +1,129 @@
+package tracker
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/mgurov/mposter/internal/assertions"
+)
+
+func Test_CircuitOpensOnConsecutiveErrors(t *testing.T) {
+	capturedOutput := bytes.Buffer{}
+
+	testee := Tracker{
+		Logger:             log.New(&capturedOutput, "", 0),
+		CircuitOpenOnCount: 2,
+	}
+
+	assertions.NoError(t, testee.Err())
+	assertCircuitState(t, CircuitClosed, testee)
+	assertions.NoError(t, testee.Err())
+	assertCircuitState(t, CircuitOpen, testee)
+
+	testee.HalfOpenCircuit(3)
+	assertCircuitState(t, CircuitHalfOpen, testee)
+
+	testee.Ok()
+	assertions.NoError(t, testee.CircuitProbed(3, true))
+	assertCircuitState(t, CircuitClosed, testee)
+
+	expectedOutput := `circuit open: 2 consecutive errors
+circuit half-open: probing
+circuit closed: probe succeeded
+`
+	assertions.StringEqual(t, "", expectedOutput, capturedOutput.String())
+}
+
+func Test_CircuitReopensOnFailedProbe(t *testing.T) {
+	capturedOutput := bytes.Buffer{}
+
+	testee := Tracker{
+		Logger:             log.New(&capturedOutput, "", 0),
+		CircuitOpenOnCount: 1,
+	}
+
+	assertions.NoError(t, testee.Err())
+	testee.HalfOpenCircuit(2)
+	assertions.NoError(t, testee.Err())
+	assertions.NoError(t, testee.CircuitProbed(2, false))
+	assertCircuitState(t, CircuitOpen, testee)
+
+	expectedOutput := `circuit open: 1 consecutive errors
+circuit half-open: probing
+circuit open: probe failed
+`
+	assertions.StringEqual(t, "", expectedOutput, capturedOutput.String())
+}
+
+func Test_CircuitErrorsWhileOpenDoNotReopen(t *testing.T) {
+	testee := Tracker{CircuitOpenOnCount: 1, CircuitMaxOpens: 1}
+
+	assertions.NoError(t, testee.Err())
+	assertions.NoError(t, testee.Err())
+	assertions.NoError(t, testee.Err())
+	assertCircuitState(t, CircuitOpen, testee)
+}
+
+func Test_CircuitMaxOpens(t *testing.T) {
+	testee := Tracker{CircuitOpenOnCount: 1, CircuitMaxOpens: 2}
+
+	assertions.NoError(t, testee.Err())
+	testee.HalfOpenCircuit(2)
+	assertions.NoError(t, testee.Err())
+	assertions.NoError(t, testee.CircuitProbed(2, false))
+	testee.HalfOpenCircuit(3)
+	assertions.NoError(t, testee.Err())
+	assertions.ErrorContains(t, "circuit opened 3 times", testee.CircuitProbed(3, false))
+}
+
+func Test_CircuitIgnoresOtherCallsWhileHalfOpen(t *testing.T) {
+	testee := Tracker{CircuitOpenOnCount: 1, CircuitMaxOpens: 1}
+
+	assertions.NoError(t, testee.Err())
+	testee.HalfOpenCircuit(5)
+
+	// the calls in flight since before the circuit opened
+	testee.Ok()
+	assertions.NoError(t, testee.CircuitProbed(3, true))
+	assertCircuitState(t, CircuitHalfOpen, testee)
+	assertions.NoError(t, testee.Err())
+	assertions.NoError(t, testee.CircuitProbed(4, false))
+	assertCircuitState(t, CircuitHalfOpen, testee)
+
+	testee.Ok()
+	assertions.NoError(t, testee.CircuitProbed(5, true))
+	assertCircuitState(t, CircuitClosed, testee)
+
+	// the probe is done with
+	assertions.NoError(t, testee.CircuitProbed(5, false))
+	assertCircuitState(t, CircuitClosed, testee)
+}
+
+func Test_CircuitNotOpenedByRowErrors(t *testing.T) {
+	testee := Tracker{CircuitOpenOnCount: 2}
+
+	assertions.NoError(t, testee.Err())
+	assertions.NoError(t, testee.RowErr("line 1: A"))
+	assertions.NoError(t, testee.RowErr("line 1: A"))
+	assertCircuitState(t, CircuitClosed, testee)
+
+	assertions.NoError(t, testee.Err())
+	assertCircuitState(t, CircuitOpen, testee)
+}
+
+func Test_CircuitDisabled(t *testing.T) {
+	testee := Tracker{}
+
+	for i := 0; i < 10; i++ {
+		assertions.NoError(t, testee.Err())
+	}
+	assertCircuitState(t, CircuitClosed, testee)
+}
+
+func assertCircuitState(t *testing.T, expected CircuitState, testee Tracker) {
+	t.Helper()
+	if testee.CircuitState() != expected {
+		t.Errorf("expected circuit %s got %s", expected, testee.CircuitState())
+	}
+}
@@ -32,6 +32,30 @@ Done 3 OK: 2 ERR: 1
 	assertions.StringEqual(t, "", expectedOutput, capturedOutput.String())
 }
 
+func Test_LogSkipOutcome(t *testing.T) {
+
+	capturedOutput := bytes.Buffer{}
+
+	testee := Tracker{
+		Logger:  log.New(&capturedOutput, "", 0),
+		TickLog: 1,
+	}
+
+	//when
+	testee.Ok()
+	testee.SkipOutcome()
+	testee.Err()
+	testee.LogDone()
+
+	expectedOutput := `1 ERR: 0
+2 ERR: 0 SKIP: 1
+3 ERR: 1 SKIP: 1
+Done 3 OK: 1 ERR: 1 SKIP: 1
+`
+
+	assertions.StringEqual(t, "", expectedOutput, capturedOutput.String())
+}
+
 func Test_LogFirstErr(t *testing.T) {
 
 	capturedOutput := bytes.Buffer{}
@@ -78,3 +102,20 @@ func Test_ShouldNotLogWhenLoggerIsNull(t *testing.T) {
 	testee.Ok()
 	testee.Err()
 }
+
+func Test_LogDoneWithStopReason(t *testing.T) {
+
+	capturedOutput := bytes.Buffer{}
+
+	testee := Tracker{
+		Logger:                    log.New(&capturedOutput, "", 0),
+		StopOnConsecutiveErrCount: 1,
+	}
+
+	//when
+	testee.Ok()
+	testee.Err()
+	testee.LogDone()
+
+	assertions.StringEqual(t, "", "Done 2 OK: 1 ERR: 1 Stopped: 1 consecutive errors\n", capturedOutput.String())
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/mgurov/mposter/internal/assertions"
+	"github.com/mgurov/mposter/internal/statuscode"
 )
 
 func Test_StopExecutionOnFirstError(t *testing.T) {
@@ -52,6 +53,12 @@ func Test_StopExecutionOnFirstError(t *testing.T) {
 	}
 }
 
+func Test_StopExecutionOnFirstRowErr(t *testing.T) {
+	testee := Tracker{StopOnFirstErr: true}
+
+	assertions.ErrorContains(t, "row error on first row at line 1: B: data missing", testee.RowErr("line 1: B: data missing"))
+}
+
 func Test_StopExecutionOnConsecutiveErrorNumber(t *testing.T) {
 	testee := Tracker{StopOnConsecutiveErrCount: 2}
 
@@ -68,6 +75,15 @@ func Test_StopExecutionOnConsecutiveErrorNumber_reset(t *testing.T) {
 	assertions.ErrorContains(t, "2 consecutive errors", testee.Err())
 }
 
+func Test_StopExecutionOnConsecutiveErrorNumber_resetBySkipOutcome(t *testing.T) {
+	testee := Tracker{StopOnConsecutiveErrCount: 2}
+
+	assertions.NoError(t, testee.Err())
+	testee.SkipOutcome()
+	assertions.NoError(t, testee.Err())
+	assertions.ErrorContains(t, "2 consecutive errors", testee.Err())
+}
+
 func Test_StopExecutionOnConsecutiveErrorNumber_disabled(t *testing.T) {
 	testee := Tracker{StopOnConsecutiveErrCount: 0}
 
@@ -82,3 +98,20 @@ func Test_StopExecutionOnConsecutiveErrorNumber_one(t *testing.T) {
 	testee.Ok()
 	assertions.ErrorContains(t, "1 consecutive errors", testee.Err())
 }
+
+func Test_StopExecutionOnHttpCode(t *testing.T) {
+	stopOn, err := statuscode.Parse("401,5xx")
+	assertions.NoError(t, err)
+	testee := Tracker{StopOnHttpCode: stopOn}
+
+	assertions.NoError(t, testee.HttpErr(404, "line 1: A"))
+	assertions.ErrorContains(t, "stop on HTTP 401 at line 2: B", testee.HttpErr(401, "line 2: B"))
+	assertions.ErrorContains(t, "stop on HTTP 503 at line 3: C", testee.HttpErr(503, "line 3: C"))
+}
+
+func Test_HttpErrRespectsOtherStopConditions(t *testing.T) {
+	testee := Tracker{StopOnConsecutiveErrCount: 2}
+
+	assertions.NoError(t, testee.HttpErr(500, "line 1: A"))
+	assertions.ErrorContains(t, "2 consecutive errors", testee.HttpErr(500, "line 2: B"))
+}
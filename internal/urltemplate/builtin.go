@@ -0,0 +1,74 @@
+package urltemplate
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFormats are the names understood by {{@now:FORMAT}} besides the Go time layouts.
+var timeFormats = map[string]func(time.Time) string{
+	"RFC3339":     func(t time.Time) string { return t.Format(time.RFC3339) },
+	"RFC3339Nano": func(t time.Time) string { return t.Format(time.RFC3339Nano) },
+	"RFC1123":     func(t time.Time) string { return t.Format(time.RFC1123) },
+	"Unix":        func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+	"UnixMilli":   func(t time.Time) string { return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10) },
+}
+
+// buildBuiltinFun resolves the placeholders starting with @: @line, @rownum, @uuid, @now[:FORMAT] and @env:NAME.
+// The environment variables are read once, when parsing, an unset one rendering empty if optional.
+func buildBuiltinFun(name string, optional bool) (RowToString, error) {
+	builtin, argument := name, ""
+	if colon := strings.Index(name, ":"); colon != -1 {
+		builtin, argument = name[:colon], name[colon+1:]
+	}
+
+	switch builtin {
+	case "@line":
+		return func(row Row) (string, error) { return row.Line, nil }, nil
+	case "@rownum":
+		return func(row Row) (string, error) { return strconv.Itoa(row.Number), nil }, nil
+	case "@uuid":
+		return func(row Row) (string, error) {
+			if row.Uuid != "" {
+				return row.Uuid, nil
+			}
+			return NewUuid(), nil
+		}, nil
+	case "@now":
+		if argument == "" {
+			argument = "RFC3339"
+		}
+		format, ok := timeFormats[argument]
+		if !ok {
+			layout := argument
+			format = func(t time.Time) string { return t.Format(layout) }
+		}
+		return func(_ Row) (string, error) { return format(time.Now()), nil }, nil
+	case "@env":
+		if argument == "" {
+			return nil, fmt.Errorf("environment variable name expected, e.g. {{@env:TOKEN}}")
+		}
+		value, ok := os.LookupEnv(argument)
+		if !ok && !optional {
+			return nil, fmt.Errorf("environment variable %s isn't set", argument)
+		}
+		return constant(value), nil
+	default:
+		return nil, fmt.Errorf("expected one of @line, @rownum, @uuid, @now, @env")
+	}
+}
+
+// NewUuid generates a random (version 4) UUID.
+func NewUuid() string {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		panic(fmt.Errorf("generate uuid: %w", err))
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
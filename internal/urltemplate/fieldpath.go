@@ -0,0 +1,122 @@
+package urltemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fieldPath addresses a value within a decoded json document, e.g. `.tenant.id` or `.items[0].sku`.
+type fieldPath struct {
+	source string
+	steps  []fieldStep
+}
+
+type fieldStep struct {
+	name  string
+	index int // for the array element steps, the name being empty then
+}
+
+func parseFieldPath(input string) (fieldPath, error) {
+	result := fieldPath{source: input}
+	if !strings.HasPrefix(input, ".") {
+		return result, fmt.Errorf("field path should start with a dot")
+	}
+
+	rest := input[1:]
+	if rest == "" {
+		return result, nil // the whole document
+	}
+
+	for rest != "" {
+		switch rest[0] {
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return result, fmt.Errorf("unterminated index in '%s'", input)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return result, fmt.Errorf("bad index '%s' in '%s'", rest[1:end], input)
+			}
+			result.steps = append(result.steps, fieldStep{index: index})
+			rest = rest[end+1:]
+		case '.':
+			rest = rest[1:]
+			fallthrough
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return result, fmt.Errorf("empty field name in '%s'", input)
+			}
+			result.steps = append(result.steps, fieldStep{name: rest[:end], index: -1})
+			rest = rest[end:]
+		}
+	}
+
+	return result, nil
+}
+
+// lookup renders the addressed value: strings as is, numbers and booleans as json literals, objects and arrays as compact json.
+// A missing field or a null value is a missingValueError.
+func (p fieldPath) lookup(document interface{}) (string, error) {
+	current := document
+	for _, step := range p.steps {
+		if step.name != "" {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return "", missingValueError{fmt.Sprintf("field %s is missing", p.source)}
+			}
+			current = object[step.name]
+		} else {
+			array, ok := current.([]interface{})
+			if !ok || step.index >= len(array) {
+				return "", missingValueError{fmt.Sprintf("field %s is missing", p.source)}
+			}
+			current = array[step.index]
+		}
+	}
+
+	switch value := current.(type) {
+	case nil:
+		return "", missingValueError{fmt.Sprintf("field %s is missing", p.source)}
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("field %s: %w", p.source, err)
+		}
+		return string(encoded), nil
+	}
+}
+
+// FieldPath addresses a value within a json document decoded with json.Decoder.UseNumber, e.g. `.status`, `$.status` or `.items[0].sku`.
+type FieldPath struct {
+	path fieldPath
+}
+
+// ParseFieldPath accepts the paths starting with a dot, optionally preceded by $.
+func ParseFieldPath(input string) (FieldPath, error) {
+	path, err := parseFieldPath(strings.TrimPrefix(input, "$"))
+	return FieldPath{path}, err
+}
+
+// Lookup renders the addressed value as the templates do: strings as is, numbers and booleans as json literals, objects and arrays as compact json.
+// Tells false if the value is missing or null.
+func (p FieldPath) Lookup(document interface{}) (string, bool) {
+	value, err := p.path.lookup(document)
+	return value, err == nil
+}
+
+func (p FieldPath) String() string {
+	return p.path.source
+}
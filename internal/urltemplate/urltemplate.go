@@ -2,44 +2,210 @@ package urltemplate
 
 import (
 	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 )
 
-type RowToString func(row []string) (string, error)
+// Row is the data of a single input line to render a template with.
+type Row struct {
+	Line     string      // the input line as is
+	Number   int         // the input line number, starting with 1
+	Uuid     string      // shared by the {{@uuid}} placeholders of all the templates rendered with the row, a new one per placeholder if empty
+	Columns  []string    // the values of the separated input
+	Document interface{} // the decoded json value of the jsonl input
+}
+
+type RowToString func(row Row) (string, error)
+
+// Syntax tells which placeholders are understood besides the numeric column indexes and how their values are escaped.
+//
+// The built-in placeholders @line, @rownum, @uuid, @now[:FORMAT] and @env:NAME are understood by any syntax.
+//
+// A placeholder might have a default value used when the value is missing or empty, e.g. `{{2:-none}}`,
+// and a section, e.g. `{{#3}}&q={{3}}{{/3}}`, is dropped when its value is missing or empty.
+//
+// A placeholder might be followed by the filters applied in order, e.g. `{{0|trim|lower}}`:
+// lower, upper, trim and base64 transform the value, while raw, path and query override its escaping.
+type Syntax struct {
+	Columns []string            // column names
+	Fields  bool                // json field paths instead of the columns
+	Url     bool                // escape the values as path segments, or as query values after the '?'
+	Escape  func(string) string // applied to the placeholder values, not to the rest of the template, takes precedence over Url
+}
 
+// Parse understands the numeric column index placeholders only, e.g. `{{0}}`.
 func Parse(input string) (RowToString, error) {
+	return Syntax{}.Parse(input)
+}
 
-	position := 0
+func (s Syntax) Parse(input string) (RowToString, error) {
+	return parse(input, s)
+}
+
+func parse(input string, placeholderSyntax Syntax) (RowToString, error) {
+	p := &templateParser{input: input, syntax: placeholderSyntax}
+	parts, err := p.parseParts("")
+	if err != nil {
+		return nil, err
+	}
+	return joined(parts), nil
+}
+
+type templateParser struct {
+	input    string
+	position int
+	inQuery  bool // a '?' met already, hence the url query context
+	syntax   Syntax
+}
+
+// parseParts parses till the end of the input or, within a section, till the placeholder closing it.
+func (p *templateParser) parseParts(section string) ([]RowToString, error) {
 
 	parts := []RowToString{}
 
-	for position < len(input) {
-		nextPlaceholderSubStart := strings.Index(input[position:], "{{")
+	for p.position < len(p.input) {
+		nextPlaceholderSubStart := strings.Index(p.input[p.position:], "{{")
 		if -1 == nextPlaceholderSubStart {
 			//reached the end of the string without new placeholder - append the remainder
-			remainder := input[position:]
-			if "" != remainder {
-				parts = append(parts, constant(remainder))
-			}
+			parts = append(parts, p.constant(p.input[p.position:]))
+			p.position = len(p.input)
 			break
 		}
 
 		if nextPlaceholderSubStart > 0 {
 			//something before the placeholder - prepend it
-			parts = append(parts, constant(input[position:position+nextPlaceholderSubStart]))
+			parts = append(parts, p.constant(p.input[p.position:p.position+nextPlaceholderSubStart]))
 		}
+		p.position += nextPlaceholderSubStart
 
-		placeholderFun, nextPosition, err := scanPlaceholder(input[position+nextPlaceholderSubStart:])
+		placeholderEnd := strings.Index(p.input[p.position:], "}}")
+		if -1 == placeholderEnd {
+			return nil, fmt.Errorf("placeholder '%s' isn't terminated", p.input[p.position:])
+		}
+		placeholderContent := p.input[p.position+2 : p.position+placeholderEnd]
+		p.position += placeholderEnd + 2
+
+		trimmed := strings.TrimSpace(placeholderContent)
+		switch {
+		case strings.HasPrefix(trimmed, "/"):
+			if section == "" || strings.TrimSpace(trimmed[1:]) != section {
+				return nil, fmt.Errorf("placeholder '{{%s}}' doesn't close any open section", placeholderContent)
+			}
+			return parts, nil
+		case strings.HasPrefix(trimmed, "#"):
+			sectionFun, err := p.parseSection(strings.TrimSpace(trimmed[1:]))
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, sectionFun)
+		default:
+			placeholderFun, err := p.buildPlaceholder(placeholderContent)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, placeholderFun)
+		}
+	}
+
+	if section != "" {
+		return nil, fmt.Errorf("section '{{#%s}}' isn't closed with '{{/%s}}'", section, section)
+	}
+	return parts, nil
+}
+
+func (p *templateParser) constant(input string) RowToString {
+	p.inQuery = p.inQuery || strings.Contains(input, "?")
+	return constant(input)
+}
+
+// parseSection renders the section content only if the value referred to is present and not empty.
+func (p *templateParser) parseSection(reference string) (RowToString, error) {
+	conditionFun, err := buildPlaceholderFun(reference, p.syntax, true)
+	if err != nil {
+		return nil, fmt.Errorf("section '{{#%s}}': %w", reference, err)
+	}
+	parts, err := p.parseParts(reference)
+	if err != nil {
+		return nil, err
+	}
+	content := joined(parts)
+
+	return func(row Row) (string, error) {
+		value, err := conditionFun(row)
+		if isMissingValue(err) || (err == nil && value == "") {
+			return "", nil
+		}
 		if err != nil {
-			return nil, err
+			return "", err
 		}
-		parts = append(parts, placeholderFun)
-		position += nextPlaceholderSubStart + nextPosition
+		return content(row)
+	}, nil
+}
+
+// buildPlaceholder applies the default value, the filters and the escaping, defaulted by the syntax and the placeholder context.
+func (p *templateParser) buildPlaceholder(placeholderContent string) (RowToString, error) {
+	filterNames := strings.Split(placeholderContent, "|")
+
+	reference, fallback, hasFallback := filterNames[0], "", false
+	if fallbackStart := strings.Index(reference, ":-"); fallbackStart != -1 {
+		reference, fallback, hasFallback = reference[:fallbackStart], strings.TrimSpace(reference[fallbackStart+2:]), true
 	}
 
-	return func(input []string) (string, error) {
+	placeholderFun, err := buildPlaceholderFun(reference, p.syntax, hasFallback)
+	if err != nil {
+		return nil, err
+	}
+	if hasFallback {
+		placeholderFun = withFallback(placeholderFun, fallback)
+	}
+
+	escape := p.syntax.Escape
+	if escape == nil && p.syntax.Url {
+		escape = url.PathEscape
+		if p.inQuery {
+			escape = url.QueryEscape
+		}
+	}
+
+	for _, filterName := range filterNames[1:] {
+		switch strings.TrimSpace(filterName) {
+		case "raw":
+			escape = nil
+		case "path":
+			escape = url.PathEscape
+		case "query":
+			escape = url.QueryEscape
+		default:
+			filter, ok := filters[strings.TrimSpace(filterName)]
+			if !ok {
+				return nil, fmt.Errorf("placeholder '{{%s}}' filter '%s' isn't recognized, expected one of raw, path, query, lower, upper, trim, base64", placeholderContent, strings.TrimSpace(filterName))
+			}
+			placeholderFun = filtered(placeholderFun, filter)
+		}
+	}
+
+	if escape != nil {
+		placeholderFun = filtered(placeholderFun, escape)
+	}
+	return placeholderFun, nil
+}
+
+func withFallback(placeholderFun RowToString, fallback string) RowToString {
+	return func(row Row) (string, error) {
+		value, err := placeholderFun(row)
+		if isMissingValue(err) || (err == nil && value == "") {
+			return fallback, nil
+		}
+		return value, err
+	}
+}
+
+func joined(parts []RowToString) RowToString {
+	return func(input Row) (string, error) {
 		result := bytes.Buffer{}
 		for _, p := range parts {
 			partString, err := p(input)
@@ -49,36 +215,97 @@ func Parse(input string) (RowToString, error) {
 			result.WriteString(partString)
 		}
 		return result.String(), nil
-	}, nil
+	}
+}
+
+// missingValueError tells the row lacks the value for the placeholder, as opposed to the value not rendering.
+type missingValueError struct {
+	message string
+}
+
+func (e missingValueError) Error() string {
+	return e.message
+}
+
+func isMissingValue(err error) bool {
+	var missing missingValueError
+	return errors.As(err, &missing)
 }
 
-func scanPlaceholder(input string) (RowToString, int, error) {
-	placeholderEnd := strings.Index(input, "}}")
-	if -1 == placeholderEnd {
-		return nil, -1, fmt.Errorf("placeholder '%s' isn't terminated", input)
+var filters = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"base64": func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	},
+}
+
+func filtered(placeholderFun RowToString, filter func(string) string) RowToString {
+	return func(row Row) (string, error) {
+		value, err := placeholderFun(row)
+		if err != nil {
+			return "", err
+		}
+		return filter(value), nil
 	}
-	placeholderContent := input[2:placeholderEnd]
-	placeholderFun, err := buildPlaceholderFun(placeholderContent)
-	return placeholderFun, placeholderEnd + 2, err
 }
 
-func buildPlaceholderFun(placeholderContent string) (RowToString, error) {
+// buildPlaceholderFun resolves the value referred to. An optional one, i.e. having a default or opening a section, might be missing.
+func buildPlaceholderFun(placeholderContent string, placeholderSyntax Syntax, optional bool) (RowToString, error) {
 	trimmed := strings.TrimSpace(placeholderContent)
+
+	if strings.HasPrefix(trimmed, "@") {
+		builtinFun, err := buildBuiltinFun(trimmed, optional)
+		if err != nil {
+			return nil, fmt.Errorf("placeholder '{{%s}}' isn't recognized: %w", placeholderContent, err)
+		}
+		return builtinFun, nil
+	}
+
+	if placeholderSyntax.Fields {
+		path, err := parseFieldPath(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("placeholder '{{%s}}' isn't recognized: %w", placeholderContent, err)
+		}
+		return func(row Row) (string, error) {
+			return path.lookup(row.Document)
+		}, nil
+	}
+
 	index, err := strconv.Atoi(trimmed)
 	if nil != err {
+		index = columnIndex(trimmed, placeholderSyntax.Columns)
+	}
+	if index < 0 {
+		if len(placeholderSyntax.Columns) > 0 {
+			return nil, fmt.Errorf("placeholder '{{%s}}' isn't recognized, columns are: %s", placeholderContent, strings.Join(placeholderSyntax.Columns, ", "))
+		}
 		return nil, fmt.Errorf("placeholder '{{%s}}' isn't recognized", placeholderContent)
 	}
 
-	return func(row []string) (string, error) {
-		if len(row)-1 < index {
-			return "", fmt.Errorf("data missing for placeholder {{%s}}", placeholderContent)
+	return func(row Row) (string, error) {
+		if len(row.Columns)-1 < index {
+			return "", missingValueError{fmt.Sprintf("data missing for placeholder {{%s}}", placeholderContent)}
 		}
 
-		return row[index], nil
+		return row.Columns[index], nil
 	}, nil
 
 }
 
+func columnIndex(name string, columns []string) int {
+	if name == "" {
+		return -1
+	}
+	for i, column := range columns {
+		if strings.TrimSpace(column) == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func constant(input string) RowToString {
-	return func(_ []string) (string, error) { return input, nil }
+	return func(_ Row) (string, error) { return input, nil }
 }
@@ -1,8 +1,13 @@
 package urltemplate
 
 import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParse(t *testing.T) {
@@ -90,7 +95,7 @@ func TestParse(t *testing.T) {
 
 			for _, tt := range t1.parsedTests {
 				t.Run(tt.name, func(t *testing.T) {
-					got, err := gotParsed(tt.rowInput)
+					got, err := gotParsed(Row{Columns: tt.rowInput})
 					if err != nil {
 						if "" == tt.wantErrContaining {
 							t.Errorf("apply parsed error = %v, want no Err", err)
@@ -151,3 +156,457 @@ func TestParseErr(t *testing.T) {
 		})
 	}
 }
+
+func TestParseWithColumns(t *testing.T) {
+	gotParsed, err := Syntax{Columns: []string{"customer_id", "order", " user "}}.Parse("/customers/{{customer_id}}/orders/{{ 1 }}?by={{ user }}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got, err := gotParsed(Row{Columns: []string{"c1", "o1", "u1"}})
+	if err != nil {
+		t.Fatalf("apply parsed error = %v", err)
+	}
+	if want := "/customers/c1/orders/o1?by=u1"; got != want {
+		t.Errorf("apply parsed = %v, want %v", got, want)
+	}
+}
+
+func TestParseWithColumnsErr(t *testing.T) {
+	_, err := Syntax{Columns: []string{"customer_id", "order"}}.Parse("/{{customer}}")
+
+	if err == nil || !strings.Contains(err.Error(), "placeholder '{{customer}}' isn't recognized, columns are: customer_id, order") {
+		t.Errorf("Parse() = err %v but want unrecognized column", err)
+	}
+}
+
+func TestParseWithFields(t *testing.T) {
+	document := map[string]interface{}{
+		"id":     "a/b",
+		"tenant": map[string]interface{}{"id": json.Number("42"), "active": true},
+		"items": []interface{}{
+			map[string]interface{}{"sku": "s1"},
+			map[string]interface{}{"sku": "s2", "tags": []interface{}{"x", "y"}},
+		},
+		"nothing": nil,
+	}
+
+	tests := []struct {
+		input             string
+		want              string
+		wantErrContaining string
+	}{
+		{input: "/{{.id}}", want: "/a/b"},
+		{input: "/t/{{ .tenant.id }}/{{.tenant.active}}", want: "/t/42/true"},
+		{input: "/{{.items[1].sku}}", want: "/s2"},
+		{input: "/{{.items[1].tags}}", want: `/["x","y"]`},
+		{input: "/{{.items[1].tags[0]}}", want: "/x"},
+		{input: "/{{.tenant.name}}", wantErrContaining: "field .tenant.name is missing"},
+		{input: "/{{.items[2].sku}}", wantErrContaining: "field .items[2].sku is missing"},
+		{input: "/{{.id.deeper}}", wantErrContaining: "field .id.deeper is missing"},
+		{input: "/{{.nothing}}", wantErrContaining: "field .nothing is missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			gotParsed, err := Syntax{Fields: true}.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := gotParsed(Row{Document: document})
+			if tt.wantErrContaining != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContaining) {
+					t.Errorf("apply parsed error = %v, wantErr containing '%s'", err, tt.wantErrContaining)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply parsed error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("apply parsed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWithFieldsErr(t *testing.T) {
+	tests := []struct {
+		input             string
+		wantErrContaining string
+	}{
+		{input: "/{{0}}", wantErrContaining: "field path should start with a dot"},
+		{input: "/{{.items[}}", wantErrContaining: "unterminated index"},
+		{input: "/{{.items[x]}}", wantErrContaining: "bad index 'x'"},
+		{input: "/{{.a..b}}", wantErrContaining: "empty field name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Syntax{Fields: true}.Parse(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrContaining) {
+				t.Errorf("Parse() = err %v but want err containing %s", err, tt.wantErrContaining)
+			}
+		})
+	}
+}
+
+func TestParseEscaped(t *testing.T) {
+	gotParsed, err := Syntax{Escape: strings.ToUpper}.Parse("a{{0}}b{{1}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got, err := gotParsed(Row{Columns: []string{"x", "y"}})
+	if err != nil {
+		t.Fatalf("apply parsed error = %v", err)
+	}
+	if want := "aXbY"; got != want {
+		t.Errorf("apply parsed = %v, want %v", got, want)
+	}
+}
+
+func TestParseUrlEscaped(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		row   []string
+		want  string
+	}{
+		{
+			name:  "path segments",
+			input: "http://host/{{0}}/sub/{{1}}",
+			row:   []string{"a/b", "c#d e"},
+			want:  "http://host/a%2Fb/sub/c%23d%20e",
+		},
+		{
+			name:  "query values",
+			input: "http://host/{{0}}?q={{1}}&r={{0}}",
+			row:   []string{"a b", "c&d+e"},
+			want:  "http://host/a%20b?q=c%26d%2Be&r=a+b",
+		},
+		{
+			name:  "raw",
+			input: "{{0|raw}}/path/{{1}}",
+			row:   []string{"http://host:8080", "a/b"},
+			want:  "http://host:8080/path/a%2Fb",
+		},
+		{
+			name:  "query forced in path",
+			input: "http://host/{{0|query}}",
+			row:   []string{"a b"},
+			want:  "http://host/a+b",
+		},
+		{
+			name:  "path forced in query",
+			input: "http://host/?q={{0|path}}",
+			row:   []string{"a b"},
+			want:  "http://host/?q=a%20b",
+		},
+		{
+			name:  "filters applied in order before escaping",
+			input: "http://host/{{0 | trim | upper}}/{{1|lower}}?q={{1|base64}}",
+			row:   []string{" ab ", "C/D"},
+			want:  "http://host/AB/c%2Fd?q=Qy9E",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotParsed, err := Syntax{Url: true}.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := gotParsed(Row{Columns: tt.row})
+			if err != nil {
+				t.Fatalf("apply parsed error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("apply parsed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEscapedRawFilter(t *testing.T) {
+	gotParsed, err := Syntax{Escape: strings.ToUpper}.Parse("a{{0|raw}}b{{1}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got, err := gotParsed(Row{Columns: []string{"x", "y"}})
+	if err != nil {
+		t.Fatalf("apply parsed error = %v", err)
+	}
+	if want := "axbY"; got != want {
+		t.Errorf("apply parsed = %v, want %v", got, want)
+	}
+}
+
+func TestParseUnknownFilter(t *testing.T) {
+	_, err := Parse("a{{0|shout}}")
+	if err == nil {
+		t.Fatal("Parse() expected error")
+	}
+	if want := "filter 'shout' isn't recognized"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Parse() error = %v, want containing %v", err, want)
+	}
+}
+
+func TestParseBuiltins(t *testing.T) {
+	os.Setenv("MPOSTER_TEST_TOKEN", "s3cr/t")
+	t.Cleanup(func() { os.Unsetenv("MPOSTER_TEST_TOKEN") })
+
+	gotParsed, err := Syntax{Url: true}.Parse("/{{@rownum}}/{{@line}}?token={{@env:MPOSTER_TEST_TOKEN}}&id={{@uuid}}&again={{@uuid}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got, err := gotParsed(Row{Line: "a b", Number: 7, Uuid: "u-1"})
+	if err != nil {
+		t.Fatalf("apply parsed error = %v", err)
+	}
+	if want := "/7/a%20b?token=s3cr%2Ft&id=u-1&again=u-1"; got != want {
+		t.Errorf("apply parsed = %v, want %v", got, want)
+	}
+}
+
+func TestParseBuiltinsWithFields(t *testing.T) {
+	gotParsed, err := Syntax{Fields: true}.Parse("{{.id}}-{{@rownum}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got, err := gotParsed(Row{Number: 3, Document: map[string]interface{}{"id": "x"}})
+	if err != nil {
+		t.Fatalf("apply parsed error = %v", err)
+	}
+	if want := "x-3"; got != want {
+		t.Errorf("apply parsed = %v, want %v", got, want)
+	}
+}
+
+func TestParseNow(t *testing.T) {
+	before := time.Now().Truncate(time.Second)
+
+	gotParsed, err := Parse("{{@now}}|{{@now:RFC3339Nano}}|{{@now:Unix}}|{{@now:2006-01-02}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := gotParsed(Row{})
+	if err != nil {
+		t.Fatalf("apply parsed error = %v", err)
+	}
+
+	parts := strings.Split(got, "|")
+	if len(parts) != 4 {
+		t.Fatalf("apply parsed = %v, want 4 parts", got)
+	}
+	for i, layout := range []string{time.RFC3339, time.RFC3339Nano} {
+		parsed, err := time.Parse(layout, parts[i])
+		if err != nil || parsed.Before(before) {
+			t.Errorf("part %d = %v, want a current %s time", i, parts[i], layout)
+		}
+	}
+	if unix, err := strconv.ParseInt(parts[2], 10, 64); err != nil || unix < before.Unix() {
+		t.Errorf("unix part = %v, want current unix time", parts[2])
+	}
+	if want := time.Now().Format("2006-01-02"); parts[3] != want {
+		t.Errorf("layout part = %v, want %v", parts[3], want)
+	}
+}
+
+func TestParseNewUuid(t *testing.T) {
+	gotParsed, err := Parse("{{@uuid}} {{@uuid}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := gotParsed(Row{})
+	if err != nil {
+		t.Fatalf("apply parsed error = %v", err)
+	}
+
+	uuids := strings.Split(got, " ")
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for _, uuid := range uuids {
+		if !uuidPattern.MatchString(uuid) {
+			t.Errorf("uuid = %v, want version 4 uuid", uuid)
+		}
+	}
+	if uuids[0] == uuids[1] {
+		t.Errorf("uuids = %v, want different ones without Row.Uuid", got)
+	}
+}
+
+func TestParseBuiltinsErr(t *testing.T) {
+	tests := []struct {
+		input             string
+		wantErrContaining string
+	}{
+		{input: "{{@nope}}", wantErrContaining: "expected one of @line"},
+		{input: "{{@env:MPOSTER_TEST_SURELY_NOT_SET}}", wantErrContaining: "environment variable MPOSTER_TEST_SURELY_NOT_SET isn't set"},
+		{input: "{{@env}}", wantErrContaining: "environment variable name expected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrContaining) {
+				t.Errorf("Parse() error = %v, want containing %v", err, tt.wantErrContaining)
+			}
+		})
+	}
+}
+
+func TestParseDefaultsAndSections(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		row   []string
+		want  string
+	}{
+		{
+			name:  "default on missing column",
+			input: "/{{0}}/{{2:-none}}",
+			row:   []string{"a"},
+			want:  "/a/none",
+		},
+		{
+			name:  "default on empty column",
+			input: "/{{0}}/{{1 :- none }}",
+			row:   []string{"a", ""},
+			want:  "/a/none",
+		},
+		{
+			name:  "value over default",
+			input: "/{{0:-none|upper}}",
+			row:   []string{"a"},
+			want:  "/A",
+		},
+		{
+			name:  "default escaped and filtered",
+			input: "/{{1:-a b|upper}}",
+			row:   []string{"x"},
+			want:  "/A%20B",
+		},
+		{
+			name:  "empty default",
+			input: "/{{0}}{{1:-}}",
+			row:   []string{"a"},
+			want:  "/a",
+		},
+		{
+			name:  "section present",
+			input: "/{{0}}?a=1{{#2}}&q={{2}}{{/2}}&b=2",
+			row:   []string{"x", "y", "z w"},
+			want:  "/x?a=1&q=z+w&b=2",
+		},
+		{
+			name:  "section missing",
+			input: "/{{0}}?a=1{{#2}}&q={{2}}{{/2}}&b=2",
+			row:   []string{"x", "y"},
+			want:  "/x?a=1&b=2",
+		},
+		{
+			name:  "section empty",
+			input: "/{{0}}?a=1{{# 1 }}&q={{1}}{{/ 1 }}",
+			row:   []string{"x", ""},
+			want:  "/x?a=1",
+		},
+		{
+			name:  "nested sections",
+			input: "{{#0}}a{{#1}}b{{1}}{{/1}}{{/0}}",
+			row:   []string{"x"},
+			want:  "a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotParsed, err := Syntax{Url: true}.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := gotParsed(Row{Columns: tt.row})
+			if err != nil {
+				t.Fatalf("apply parsed error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("apply parsed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldDefaultsAndSections(t *testing.T) {
+	gotParsed, err := Syntax{Fields: true}.Parse("/{{.id}}/{{.kind:-any}}{{#.tag}}?tag={{.tag}}{{/.tag}}-{{@env:MPOSTER_TEST_SURELY_NOT_SET:-noenv}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for document, want := range map[string]string{
+		`{"id": 1, "kind": "k", "tag": "t"}`: "/1/k?tag=t-noenv",
+		`{"id": 1, "kind": null}`:            "/1/any-noenv",
+	} {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(document), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		got, err := gotParsed(Row{Document: decoded})
+		if err != nil {
+			t.Fatalf("apply parsed error = %v", err)
+		}
+		if got != want {
+			t.Errorf("apply parsed = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestParseSectionsErr(t *testing.T) {
+	tests := []struct {
+		input             string
+		wantErrContaining string
+	}{
+		{input: "a{{#0}}b", wantErrContaining: "section '{{#0}}' isn't closed with '{{/0}}'"},
+		{input: "a{{#0}}b{{/1}}", wantErrContaining: "placeholder '{{/1}}' doesn't close any open section"},
+		{input: "a{{/0}}", wantErrContaining: "placeholder '{{/0}}' doesn't close any open section"},
+		{input: "a{{#foo}}b{{/foo}}", wantErrContaining: "section '{{#foo}}': placeholder '{{foo}}' isn't recognized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrContaining) {
+				t.Errorf("Parse() error = %v, want containing %v", err, tt.wantErrContaining)
+			}
+		})
+	}
+}
+
+func TestParseFieldPath(t *testing.T) {
+	document := map[string]interface{}{
+		"status": "done",
+		"items":  []interface{}{map[string]interface{}{"count": json.Number("3")}},
+	}
+
+	tests := []struct {
+		input     string
+		want      string
+		wantFound bool
+	}{
+		{input: ".status", want: "done", wantFound: true},
+		{input: "$.status", want: "done", wantFound: true},
+		{input: "$.items[0].count", want: "3", wantFound: true},
+		{input: ".error", wantFound: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			path, err := ParseFieldPath(tt.input)
+			if err != nil {
+				t.Fatalf("ParseFieldPath() error = %v", err)
+			}
+			got, found := path.Lookup(document)
+			if got != tt.want || found != tt.wantFound {
+				t.Errorf("Lookup() = %v, %v, want %v, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+
+	if _, err := ParseFieldPath("status"); err == nil {
+		t.Error("expected an error for the path not starting with a dot")
+	}
+}
@@ -2,9 +2,13 @@ package system_test
 
 import (
 	"bytes"
+	"fmt"
+	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mgurov/mposter/internal/assertions"
 	"github.com/mgurov/mposter/internal/testserver"
@@ -56,6 +60,84 @@ func TestUnknownFlag(t *testing.T) {
 	}
 }
 
+func TestGracefulInterrupt(t *testing.T) {
+
+	server := testserver.StartNewTestServer()
+	defer server.Shutdown()
+
+	var result runResultType
+	cmd := exec.Command("../../build/out/mposter", server.Addr()+"/path/")
+	cmd.Stdout = &result.stdOut
+	cmd.Stderr = &result.stdErr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the input is kept open so the reading is blocked when the signal comes
+	fmt.Fprintln(stdin, "A")
+	for i := 0; i < 100 && server.AccessLog() == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cmd.Process.Signal(os.Interrupt)
+
+	err = cmd.Wait()
+	stdin.Close()
+
+	if ee, ok := err.(*exec.ExitError); !ok || ee.ExitCode() != 130 {
+		t.Errorf("expected exit code 130, got %v", err)
+	}
+
+	assertions.StringEqual(t, "stdout", "A OK\n", result.stdOut.String())
+
+	assertions.OnlyLinesContaining(t, "errstr", []string{
+		"status 204: 1 Stopped: interrupted (interrupt)",
+		"interrupted (interrupt), to continue re-run with --skip=1",
+	}, result.stdErr.String())
+}
+
+func TestContinueCsvAfterInterrupt(t *testing.T) {
+
+	server := testserver.StartNewTestServer()
+	defer server.Shutdown()
+
+	var result runResultType
+	cmd := exec.Command("../../build/out/mposter", "--input-format=csv", server.Addr()+"/path/{{id}}")
+	cmd.Stdout = &result.stdOut
+	cmd.Stderr = &result.stdErr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Fprint(stdin, "id,name\n1,a\n")
+	for i := 0; i < 100 && server.AccessLog() == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cmd.Process.Signal(os.Interrupt)
+	cmd.Wait()
+	stdin.Close()
+
+	hint := regexp.MustCompile(`to continue re-run with (--skip=\d+)`).FindStringSubmatch(result.stdErr.String())
+	if hint == nil {
+		t.Fatalf("expected the continue hint, got %s", result.stdErr.String())
+	}
+
+	continued := runWithErr("mposter --input-format=csv "+hint[1]+" '"+server.Addr()+"/path/{{id}}'", "id,name\n1,a\n2,b\n", t)
+
+	if continued.exitCode != nil {
+		t.Error("Unexpected error code", continued.exitCode.ExitCode(), continued.stdErr.String())
+	}
+	assertions.StringEqual(t, "stdout", "2,b OK\n", continued.stdOut.String())
+	assertions.StringEqual(t, "http access log", "POST /path/1\nPOST /path/2\n", server.AccessLog())
+}
+
 func run(command, input string, t *testing.T) string {
 	runResult := runWithErr(command, input, t)
 
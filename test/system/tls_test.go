@@ -0,0 +1,94 @@
+package system_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/mgurov/mposter/internal/assertions"
+	"github.com/mgurov/mposter/internal/testserver"
+)
+
+func TestTlsWithCustomCa(t *testing.T) {
+
+	certificates := generateCertificates(t, "localhost")
+	server := startTlsServer(certificates, false)
+	defer server.Shutdown()
+
+	stdOut := run("mposter --tick=-1 --cacert="+certificates.CaCertFile+" "+server.Addr()+"/path/", "A\n", t)
+
+	assertions.StringEqual(t, "stdout", "A OK\n", stdOut)
+	assertions.StringEqual(t, "http access log", "POST /path/A\n", server.AccessLog())
+}
+
+func TestTlsShouldRejectUnknownCa(t *testing.T) {
+
+	certificates := generateCertificates(t, "localhost")
+	server := startTlsServer(certificates, false)
+	defer server.Shutdown()
+
+	runResult := runWithErr("mposter --tick=-1 --stop-on-first-err=false "+server.Addr()+"/path/", "A\n", t)
+
+	assertions.OnlyLinesContaining(t, "stdout", []string{"A ERR Post"}, runResult.stdOut.String())
+	assertions.StringEqual(t, "http access log", "", server.AccessLog())
+}
+
+func TestTlsInsecure(t *testing.T) {
+
+	certificates := generateCertificates(t, "localhost")
+	server := startTlsServer(certificates, false)
+	defer server.Shutdown()
+
+	runResult := runWithErr("mposter --tick=-1 --insecure "+server.Addr()+"/path/", "A\n", t)
+
+	assertions.StringEqual(t, "stdout", "A OK\n", runResult.stdOut.String())
+	assertions.OnlyLinesContaining(t, "stderr", []string{"WARNING: --insecure"}, runResult.stdErr.String())
+}
+
+func TestTlsServerName(t *testing.T) {
+
+	certificates := generateCertificates(t, "mposter.test")
+	server := startTlsServer(certificates, false)
+	defer server.Shutdown()
+
+	stdOut := run("mposter --tick=-1 --cacert="+certificates.CaCertFile+" --tls-server-name=mposter.test "+server.Addr()+"/path/", "A\n", t)
+
+	assertions.StringEqual(t, "stdout", "A OK\n", stdOut)
+}
+
+func TestTlsClientCertificate(t *testing.T) {
+
+	certificates := generateCertificates(t, "localhost")
+	server := startTlsServer(certificates, true)
+	defer server.Shutdown()
+
+	stdOut := run("mposter --tick=-1 --cacert="+certificates.CaCertFile+" --cert="+certificates.ClientCertFile+" --key="+certificates.ClientKeyFile+" "+server.Addr()+"/path/", "A\n", t)
+	assertions.StringEqual(t, "stdout", "A OK\n", stdOut)
+
+	runResult := runWithErr("mposter --tick=-1 --stop-on-first-err=false --cacert="+certificates.CaCertFile+" "+server.Addr()+"/path/", "B\n", t)
+	assertions.OnlyLinesContaining(t, "stdout", []string{"B ERR Post"}, runResult.stdOut.String())
+
+	assertions.StringEqual(t, "http access log", "POST /path/A\n", server.AccessLog())
+}
+
+func generateCertificates(t *testing.T, serverName string) *testserver.TestCertificates {
+	dir, err := ioutil.TempDir("", "mposter-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	certificates, err := testserver.GenerateCertificates(dir, serverName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificates
+}
+
+func startTlsServer(certificates *testserver.TestCertificates, requireClientCert bool) *testserver.TestServer {
+	server := testserver.NewTestServer()
+	server.TLS = certificates
+	server.RequireClientCert = requireClientCert
+	server.Start()
+	return &server
+}